@@ -13,34 +13,142 @@ func init() {
 }
 // initEn will init en support.
 func initEn(tag language.Tag) {
+	message.SetString(tag, "%d messages of %s updated.", "%d messages of %s updated.")
 	message.SetString(tag, "%s gen_bot_events [module] [outfile]", "%s gen_bot_events [module] [outfile]")
+	message.SetString(tag, "%s record --token [token] --out [file.jsonl]", "%s record --token [token] --out [file.jsonl]")
+	message.SetString(tag, "%s replay --in [file.jsonl] --webhook [url]", "%s replay --in [file.jsonl] --webhook [url]")
+	message.SetString(tag, "%s validate_locales [dir]", "%s validate_locales [dir]")
+	message.SetString(tag, "--in or --mongo is required.", "--in or --mongo is required.")
+	message.SetString(tag, "--out or --mongo is required.", "--out or --mongo is required.")
+	message.SetString(tag, "Bot API server URL", "Bot API server URL")
 	message.SetString(tag, "Echo framework's CLI scaffolding tool", "Echo framework's CLI scaffolding tool")
 	message.SetString(tag, "Generate Bot Events", "Generate Bot Events")
+	message.SetString(tag, "JSON file of translator notes", "JSON file of translator notes")
+	message.SetString(tag, "JSONL file to read", "JSONL file to read")
+	message.SetString(tag, "JSONL file to write", "JSONL file to write")
+	message.SetString(tag, "Record bot updates", "Record bot updates")
+	message.SetString(tag, "Replay recorded updates to a webhook", "Replay recorded updates to a webhook")
+	message.SetString(tag, "Validate ICU messages of locale files", "Validate ICU messages of locale files")
+	message.SetString(tag, "[file] can't be empty.", "[file] can't be empty.")
+	message.SetString(tag, "[lang] can't be empty.", "[lang] can't be empty.")
 	message.SetString(tag, "[module] can't be empty.", "[module] can't be empty.")
 	message.SetString(tag, "[project name] can't be empty.", "[project name] can't be empty.")
 	message.SetString(tag, "a tool for managing message translations.", "a tool for managing message translations.")
+	message.SetString(tag, "bot token", "bot token")
 	message.SetString(tag, "create a project", "create a project")
+	message.SetString(tag, "directory of the locale files", "directory of the locale files")
+	message.SetString(tag, "exchange translations with CAT tools", "exchange translations with CAT tools")
+	message.SetString(tag, "export a locale as XLIFF 2.0 or PO", "export a locale as XLIFF 2.0 or PO")
+	message.SetString(tag, "file to write, stdout by default", "file to write, stdout by default")
+	message.SetString(tag, "max wait between two updates", "max wait between two updates")
+	message.SetString(tag, "merge translations from XLIFF 2.0 or PO into the locale files", "merge translations from XLIFF 2.0 or PO into the locale files")
+	message.SetString(tag, "mongo collection to read", "mongo collection to read")
+	message.SetString(tag, "mongo collection to write", "mongo collection to write")
+	message.SetString(tag, "only updates after this time", "only updates after this time")
+	message.SetString(tag, "only updates before this time", "only updates before this time")
+	message.SetString(tag, "only updates of this bot ID", "only updates of this bot ID")
+	message.SetString(tag, "only updates of this chat ID", "only updates of this chat ID")
+	message.SetString(tag, "only updates of this user ID", "only updates of this user ID")
 	message.SetString(tag, "print only the version", "print only the version")
+	message.SetString(tag, "source language", "source language")
+	message.SetString(tag, "target language, read from the file by default", "target language, read from the file by default")
+	message.SetString(tag, "the bot has a webhook, remove it before recording.", "the bot has a webhook, remove it before recording.")
+	message.SetString(tag, "time compression, 0 replays without waiting", "time compression, 0 replays without waiting")
+	message.SetString(tag, "webhook URL of the local server", "webhook URL of the local server")
+	message.SetString(tag, "webhook secret token", "webhook secret token")
+	message.SetString(tag, "xliff or po, guessed from --out by default", "xliff or po, guessed from --out by default")
 }
 // initZhhans will init zh-hans support.
 func initZhhans(tag language.Tag) {
+	message.SetString(tag, "%d messages of %s updated.", "%d messages of %s updated.")
 	message.SetString(tag, "%s gen_bot_events [module] [outfile]", "%s gen_bot_events [module] [outfile]")
+	message.SetString(tag, "%s record --token [token] --out [file.jsonl]", "%s record --token [token] --out [file.jsonl]")
+	message.SetString(tag, "%s replay --in [file.jsonl] --webhook [url]", "%s replay --in [file.jsonl] --webhook [url]")
+	message.SetString(tag, "%s validate_locales [dir]", "%s validate_locales [dir]")
+	message.SetString(tag, "--in or --mongo is required.", "--in or --mongo is required.")
+	message.SetString(tag, "--out or --mongo is required.", "--out or --mongo is required.")
+	message.SetString(tag, "Bot API server URL", "Bot API server URL")
 	message.SetString(tag, "Echo framework's CLI scaffolding tool", "Echo framework's CLI scaffolding tool")
 	message.SetString(tag, "Generate Bot Events", "Generate Bot Events")
+	message.SetString(tag, "JSON file of translator notes", "JSON file of translator notes")
+	message.SetString(tag, "JSONL file to read", "JSONL file to read")
+	message.SetString(tag, "JSONL file to write", "JSONL file to write")
+	message.SetString(tag, "Record bot updates", "Record bot updates")
+	message.SetString(tag, "Replay recorded updates to a webhook", "Replay recorded updates to a webhook")
+	message.SetString(tag, "Validate ICU messages of locale files", "Validate ICU messages of locale files")
+	message.SetString(tag, "[file] can't be empty.", "[file] can't be empty.")
+	message.SetString(tag, "[lang] can't be empty.", "[lang] can't be empty.")
 	message.SetString(tag, "[module] can't be empty.", "[module] can't be empty.")
 	message.SetString(tag, "[project name] can't be empty.", "[project name] can't be empty.")
 	message.SetString(tag, "a tool for managing message translations.", "a tool for managing message translations.")
+	message.SetString(tag, "bot token", "bot token")
 	message.SetString(tag, "create a project", "create a project")
+	message.SetString(tag, "directory of the locale files", "directory of the locale files")
+	message.SetString(tag, "exchange translations with CAT tools", "exchange translations with CAT tools")
+	message.SetString(tag, "export a locale as XLIFF 2.0 or PO", "export a locale as XLIFF 2.0 or PO")
+	message.SetString(tag, "file to write, stdout by default", "file to write, stdout by default")
+	message.SetString(tag, "max wait between two updates", "max wait between two updates")
+	message.SetString(tag, "merge translations from XLIFF 2.0 or PO into the locale files", "merge translations from XLIFF 2.0 or PO into the locale files")
+	message.SetString(tag, "mongo collection to read", "mongo collection to read")
+	message.SetString(tag, "mongo collection to write", "mongo collection to write")
+	message.SetString(tag, "only updates after this time", "only updates after this time")
+	message.SetString(tag, "only updates before this time", "only updates before this time")
+	message.SetString(tag, "only updates of this bot ID", "only updates of this bot ID")
+	message.SetString(tag, "only updates of this chat ID", "only updates of this chat ID")
+	message.SetString(tag, "only updates of this user ID", "only updates of this user ID")
 	message.SetString(tag, "print only the version", "print only the version")
+	message.SetString(tag, "source language", "source language")
+	message.SetString(tag, "target language, read from the file by default", "target language, read from the file by default")
+	message.SetString(tag, "the bot has a webhook, remove it before recording.", "the bot has a webhook, remove it before recording.")
+	message.SetString(tag, "time compression, 0 replays without waiting", "time compression, 0 replays without waiting")
+	message.SetString(tag, "webhook URL of the local server", "webhook URL of the local server")
+	message.SetString(tag, "webhook secret token", "webhook secret token")
+	message.SetString(tag, "xliff or po, guessed from --out by default", "xliff or po, guessed from --out by default")
 }
 // initZhhant will init zh-hant support.
 func initZhhant(tag language.Tag) {
+	message.SetString(tag, "%d messages of %s updated.", "%d messages of %s updated.")
 	message.SetString(tag, "%s gen_bot_events [module] [outfile]", "%s gen_bot_events [module] [outfile]")
+	message.SetString(tag, "%s record --token [token] --out [file.jsonl]", "%s record --token [token] --out [file.jsonl]")
+	message.SetString(tag, "%s replay --in [file.jsonl] --webhook [url]", "%s replay --in [file.jsonl] --webhook [url]")
+	message.SetString(tag, "%s validate_locales [dir]", "%s validate_locales [dir]")
+	message.SetString(tag, "--in or --mongo is required.", "--in or --mongo is required.")
+	message.SetString(tag, "--out or --mongo is required.", "--out or --mongo is required.")
+	message.SetString(tag, "Bot API server URL", "Bot API server URL")
 	message.SetString(tag, "Echo framework's CLI scaffolding tool", "Echo framework's CLI scaffolding tool")
 	message.SetString(tag, "Generate Bot Events", "Generate Bot Events")
+	message.SetString(tag, "JSON file of translator notes", "JSON file of translator notes")
+	message.SetString(tag, "JSONL file to read", "JSONL file to read")
+	message.SetString(tag, "JSONL file to write", "JSONL file to write")
+	message.SetString(tag, "Record bot updates", "Record bot updates")
+	message.SetString(tag, "Replay recorded updates to a webhook", "Replay recorded updates to a webhook")
+	message.SetString(tag, "Validate ICU messages of locale files", "Validate ICU messages of locale files")
+	message.SetString(tag, "[file] can't be empty.", "[file] can't be empty.")
+	message.SetString(tag, "[lang] can't be empty.", "[lang] can't be empty.")
 	message.SetString(tag, "[module] can't be empty.", "[module] can't be empty.")
 	message.SetString(tag, "[project name] can't be empty.", "[project name] can't be empty.")
 	message.SetString(tag, "a tool for managing message translations.", "a tool for managing message translations.")
+	message.SetString(tag, "bot token", "bot token")
 	message.SetString(tag, "create a project", "create a project")
+	message.SetString(tag, "directory of the locale files", "directory of the locale files")
+	message.SetString(tag, "exchange translations with CAT tools", "exchange translations with CAT tools")
+	message.SetString(tag, "export a locale as XLIFF 2.0 or PO", "export a locale as XLIFF 2.0 or PO")
+	message.SetString(tag, "file to write, stdout by default", "file to write, stdout by default")
+	message.SetString(tag, "max wait between two updates", "max wait between two updates")
+	message.SetString(tag, "merge translations from XLIFF 2.0 or PO into the locale files", "merge translations from XLIFF 2.0 or PO into the locale files")
+	message.SetString(tag, "mongo collection to read", "mongo collection to read")
+	message.SetString(tag, "mongo collection to write", "mongo collection to write")
+	message.SetString(tag, "only updates after this time", "only updates after this time")
+	message.SetString(tag, "only updates before this time", "only updates before this time")
+	message.SetString(tag, "only updates of this bot ID", "only updates of this bot ID")
+	message.SetString(tag, "only updates of this chat ID", "only updates of this chat ID")
+	message.SetString(tag, "only updates of this user ID", "only updates of this user ID")
 	message.SetString(tag, "print only the version", "print only the version")
+	message.SetString(tag, "source language", "source language")
+	message.SetString(tag, "target language, read from the file by default", "target language, read from the file by default")
+	message.SetString(tag, "the bot has a webhook, remove it before recording.", "the bot has a webhook, remove it before recording.")
+	message.SetString(tag, "time compression, 0 replays without waiting", "time compression, 0 replays without waiting")
+	message.SetString(tag, "webhook URL of the local server", "webhook URL of the local server")
+	message.SetString(tag, "webhook secret token", "webhook secret token")
+	message.SetString(tag, "xliff or po, guessed from --out by default", "xliff or po, guessed from --out by default")
 }
@@ -17,6 +17,8 @@ import (
 // Generate Bot Events
 func GenBotEvents(module string, outFile string) error {
 	events := []string{}
+	// NextFn 是 telebot handler 时，生成 []tele.HandlerFunc
+	teleEvents := false
 	if err := filepath.Walk("./app", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -48,6 +50,7 @@ func GenBotEvents(module string, outFile string) error {
 		filePackName := file.Name.Name
 
 		currentPackName := getCurrentPackName(file)
+		fsmPackName := getFSMPackName(file)
 		ast.Inspect(file, func(n ast.Node) bool {
 			switch v := n.(type) {
 			case *ast.CallExpr:
@@ -56,13 +59,16 @@ func GenBotEvents(module string, outFile string) error {
 					if pack, ok := fn.X.(*ast.Ident); ok {
 						packName = pack.Name
 					}
-					if packName != currentPackName {
+					if packName == "" || (packName != currentPackName && packName != fsmPackName) {
 						return true
 					}
 					funcName := fn.Sel.Name
-					if funcName != "SetFSMValue" {
+					if funcName != "SetFSMValue" || len(v.Args) < 2 {
 						return true
 					}
+					if packName == fsmPackName {
+						teleEvents = true
+					}
 
 					var FSMValue *ast.CompositeLit
 					if FSMValue, ok = v.Args[1].(*ast.CompositeLit); !ok {
@@ -114,11 +120,11 @@ package routers
 
 import (
 	{{if .Data}}app "{{.Package}}/app"{{end}}
-	"github.com/labstack/echo/v4"
+	{{if .Tele}}tele "gopkg.in/telebot.v4"{{else}}"github.com/labstack/echo/v4"{{end}}
 )
 
 // BotFSMEvents is bot FSM events
-var BotFSMEvents = []echo.HandlerFunc{
+var BotFSMEvents = []{{if .Tele}}tele{{else}}echo{{end}}.HandlerFunc{
 {{- range $k, $v := .Data }}
 	{{$v}},
 {{- end }}
@@ -133,9 +139,11 @@ var BotFSMEvents = []echo.HandlerFunc{
 	return tmpl.Execute(goFile, struct {
 		Data    []string
 		Package string
+		Tele    bool
 	}{
 		events,
 		module,
+		teleEvents,
 	})
 }
 
@@ -152,6 +160,19 @@ func getCurrentPackName(file *ast.File) string {
 	return ""
 }
 
+// getFSMPackName
+func getFSMPackName(file *ast.File) string {
+	for _, i := range file.Imports {
+		if i.Path.Kind == token.STRING && i.Path.Value == `"github.com/mylukin/EchoPilot/service/fsm"` {
+			if i.Name == nil {
+				return removeQuotesAndExtractLastPart(i.Path.Value)
+			}
+			return i.Name.Name
+		}
+	}
+	return ""
+}
+
 // removeQuotesAndExtractLastPart 会去除字符串两边的双引号，并返回最后一个斜杠后面的部分。
 func removeQuotesAndExtractLastPart(input string) string {
 	// 去除两边的双引号
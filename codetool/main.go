@@ -4,8 +4,11 @@ import (
 	"errors"
 	"log"
 	"os"
+	"time"
 
 	"github.com/Xuanwo/go-locale"
+	"github.com/mylukin/EchoPilot/service/i18n"
+	"github.com/mylukin/EchoPilot/service/recorder"
 	ei18n "github.com/mylukin/easy-i18n/i18n"
 	"github.com/urfave/cli/v2"
 )
@@ -45,6 +48,68 @@ func main() {
 					return GenBotEvents(module, outFile)
 				},
 			},
+			{
+				Name:      "record",
+				Usage:     ei18n.Sprintf(`Record bot updates`),
+				UsageText: ei18n.Sprintf(`%s record --token [token] --out [file.jsonl]`, appName),
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "token", Usage: ei18n.Sprintf(`bot token`), EnvVars: []string{"BOT_TOKEN"}, Required: true},
+					&cli.StringFlag{Name: "out", Usage: ei18n.Sprintf(`JSONL file to write`)},
+					&cli.StringFlag{Name: "mongo", Usage: ei18n.Sprintf(`mongo collection to write`)},
+					&cli.StringFlag{Name: "api", Usage: ei18n.Sprintf(`Bot API server URL`)},
+				},
+				Action: func(c *cli.Context) error {
+					return RecordUpdates(c.String("token"), c.String("out"), c.String("mongo"), c.String("api"))
+				},
+			},
+			{
+				Name:      "replay",
+				Usage:     ei18n.Sprintf(`Replay recorded updates to a webhook`),
+				UsageText: ei18n.Sprintf(`%s replay --in [file.jsonl] --webhook [url]`, appName),
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "in", Usage: ei18n.Sprintf(`JSONL file to read`)},
+					&cli.StringFlag{Name: "mongo", Usage: ei18n.Sprintf(`mongo collection to read`)},
+					&cli.StringFlag{Name: "webhook", Usage: ei18n.Sprintf(`webhook URL of the local server`), Required: true},
+					&cli.StringFlag{Name: "secret", Usage: ei18n.Sprintf(`webhook secret token`)},
+					&cli.Float64Flag{Name: "speed", Usage: ei18n.Sprintf(`time compression, 0 replays without waiting`), Value: 1},
+					&cli.DurationFlag{Name: "max-gap", Usage: ei18n.Sprintf(`max wait between two updates`)},
+					&cli.Int64Flag{Name: "bot", Usage: ei18n.Sprintf(`only updates of this bot ID`)},
+					&cli.Int64Flag{Name: "chat", Usage: ei18n.Sprintf(`only updates of this chat ID`)},
+					&cli.Int64Flag{Name: "user", Usage: ei18n.Sprintf(`only updates of this user ID`)},
+					&cli.TimestampFlag{Name: "from", Usage: ei18n.Sprintf(`only updates after this time`), Layout: time.RFC3339},
+					&cli.TimestampFlag{Name: "to", Usage: ei18n.Sprintf(`only updates before this time`), Layout: time.RFC3339},
+				},
+				Action: func(c *cli.Context) error {
+					filter := recorder.Filter{
+						BotID:  c.Int64("bot"),
+						ChatID: c.Int64("chat"),
+						UserID: c.Int64("user"),
+					}
+					if from := c.Timestamp("from"); from != nil {
+						filter.From = *from
+					}
+					if to := c.Timestamp("to"); to != nil {
+						filter.To = *to
+					}
+					options := recorder.ReplayOptions{
+						Speed:  c.Float64("speed"),
+						MaxGap: c.Duration("max-gap"),
+					}
+					return ReplayUpdates(c.String("in"), c.String("mongo"), c.String("webhook"), c.String("secret"), filter, options)
+				},
+			},
+			{
+				Name:      "validate_locales",
+				Usage:     ei18n.Sprintf(`Validate ICU messages of locale files`),
+				UsageText: ei18n.Sprintf(`%s validate_locales [dir]`, appName),
+				Action: func(c *cli.Context) error {
+					dir := c.Args().Get(0)
+					if dir == "" {
+						dir = "./locales"
+					}
+					return i18n.ValidateFS(os.DirFS(dir), ".")
+				},
+			},
 		},
 	}
 
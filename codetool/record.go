@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/service/recorder"
+	ei18n "github.com/mylukin/easy-i18n/i18n"
+	tele "gopkg.in/telebot.v4"
+)
+
+// RecordUpdates poll updates of the bot and record them until interrupted
+func RecordUpdates(token, out, collection, apiURL string) error {
+	writer, err := newRecordWriter(out, collection)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	botID := helper.GetBotID(token)
+	count := 0
+	poller := tele.NewMiddlewarePoller(&tele.LongPoller{Timeout: 10 * time.Second}, func(update *tele.Update) bool {
+		record, err := recorder.NewRecord(botID, *update)
+		if err == nil {
+			err = writer.Write(record)
+		}
+		if err != nil {
+			log.Errorf("update %d: %v", update.ID, err)
+			return false
+		}
+		count++
+		log.Infof("recorded update %d", update.ID)
+		// 只记录，不处理
+		return false
+	})
+
+	bot, err := tele.NewBot(tele.Settings{URL: apiURL, Token: token, Poller: poller})
+	if err != nil {
+		return errors.New(helper.HiddenBotToken(err.Error()))
+	}
+	// getUpdates 与 webhook 不能同时使用
+	if webhook, err := bot.Webhook(); err == nil && webhook.Listen != "" {
+		return errors.New(ei18n.Sprintf(`the bot has a webhook, remove it before recording.`))
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		bot.Stop()
+	}()
+
+	log.Infof("recording updates of bot %d, press Ctrl+C to stop", botID)
+	bot.Start()
+	log.Infof("%d updates recorded", count)
+	return nil
+}
+
+// ReplayUpdates post recorded updates to a local webhook
+func ReplayUpdates(in, collection, webhook, secret string, filter recorder.Filter, options recorder.ReplayOptions) error {
+	var records []recorder.Record
+	var err error
+	switch {
+	case in != "":
+		records, err = recorder.ReadJSONL(in, filter)
+	case collection != "":
+		records, err = recorder.ReadMongo(collection, filter)
+	default:
+		return errors.New(ei18n.Sprintf(`--in or --mongo is required.`))
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("replaying %d updates to %s", len(records), webhook)
+	return recorder.Replay(records, options, recorder.ToWebhook(webhook, secret))
+}
+
+// newRecordWriter JSONL file or mongo collection
+func newRecordWriter(out, collection string) (recorder.Writer, error) {
+	switch {
+	case out != "":
+		return recorder.NewJSONLWriter(out)
+	case collection != "":
+		return recorder.NewMongoWriter(collection), nil
+	}
+	return nil, errors.New(ei18n.Sprintf(`--out or --mongo is required.`))
+}
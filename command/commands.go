@@ -6,5 +6,6 @@ import "github.com/urfave/cli/v2"
 func RegisterCommands(app *cli.App) {
 	app.Commands = append(app.Commands,
 		&CreateProjectCommand,
+		&I18nCommand,
 	)
 }
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/mylukin/EchoPilot/service/i18n"
+	ei18n "github.com/mylukin/easy-i18n/i18n"
+	"github.com/urfave/cli/v2"
+)
+
+var I18nCommand = cli.Command{
+	Name:  "i18n",
+	Usage: ei18n.Sprintf("exchange translations with CAT tools"),
+	Subcommands: []*cli.Command{
+		{
+			Name:      "export",
+			Usage:     ei18n.Sprintf("export a locale as XLIFF 2.0 or PO"),
+			ArgsUsage: `[lang]`,
+			Flags: append(i18nFlags(),
+				&cli.StringFlag{Name: "out", Aliases: []string{"o"}, Usage: ei18n.Sprintf("file to write, stdout by default")},
+				&cli.StringFlag{Name: "format", Usage: ei18n.Sprintf("xliff or po, guessed from --out by default")},
+			),
+			Action: func(c *cli.Context) error {
+				lang := c.Args().Get(0)
+				if lang == "" {
+					return errors.New(ei18n.Sprintf(`[lang] can't be empty.`))
+				}
+				return exportLocale(c.String("dir"), c.String("source"), lang, c.String("notes"), c.String("out"), c.String("format"))
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     ei18n.Sprintf("merge translations from XLIFF 2.0 or PO into the locale files"),
+			ArgsUsage: `[file]`,
+			Flags: append(i18nFlags(),
+				&cli.StringFlag{Name: "lang", Usage: ei18n.Sprintf("target language, read from the file by default")},
+			),
+			Action: func(c *cli.Context) error {
+				file := c.Args().Get(0)
+				if file == "" {
+					return errors.New(ei18n.Sprintf(`[file] can't be empty.`))
+				}
+				return importLocale(c.String("dir"), c.String("source"), c.String("lang"), c.String("notes"), file)
+			},
+		},
+	},
+}
+
+// i18nFlags 导入导出共用的参数
+func i18nFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "dir", Value: "./locales", Usage: ei18n.Sprintf("directory of the locale files")},
+		&cli.StringFlag{Name: "source", Value: "en", Usage: ei18n.Sprintf("source language")},
+		&cli.StringFlag{Name: "notes", Usage: ei18n.Sprintf("JSON file of translator notes")},
+	}
+}
+
+// exportLocale 导出翻译文件
+func exportLocale(dir, source, lang, notesFile, out, format string) error {
+	catalog, err := i18n.LoadCatalog(dir, source, lang)
+	if err != nil {
+		return err
+	}
+	if catalog.Notes, err = readNotes(notesFile); err != nil {
+		return err
+	}
+	if format == "" {
+		format = i18n.ExchangeFormat(out)
+	}
+
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return catalog.Export(w, format)
+}
+
+// importLocale 将翻译合并回 <lang>.json，并保存译者备注
+func importLocale(dir, source, lang, notesFile, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	format := i18n.ExchangeFormat(file)
+	if lang == "" {
+		if lang, err = i18n.TargetLanguage(data, format); err != nil {
+			return err
+		}
+	}
+	catalog, err := i18n.LoadCatalog(dir, source, lang)
+	if err != nil {
+		return err
+	}
+	if catalog.Notes, err = readNotes(notesFile); err != nil {
+		return err
+	}
+
+	changed, importErr := catalog.Import(bytes.NewReader(data), format)
+	if importErr != nil && changed == 0 {
+		return importErr
+	}
+	if err := catalog.Save(dir); err != nil {
+		return err
+	}
+	if notesFile != "" && len(catalog.Notes) > 0 {
+		buf, err := json.MarshalIndent(catalog.Notes, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(notesFile, append(buf, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	log.Println(ei18n.Sprintf("%d messages of %s updated.", changed, lang))
+	return importErr
+}
+
+// readNotes 读取译者备注，文件不存在时为空
+func readNotes(file string) (map[string]string, error) {
+	notes := map[string]string{}
+	if file == "" {
+		return notes, nil
+	}
+	buf, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return notes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return notes, json.Unmarshal(buf, &notes)
+}
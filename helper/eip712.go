@@ -0,0 +1,439 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// eip712DomainType is the reserved type name of the EIP-712 domain
+const eip712DomainType = "EIP712Domain"
+
+var (
+	eip712ArrayRegex = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+	eip712IntRegex   = regexp.MustCompile(`^(u?int)(\d*)$`)
+	eip712BytesRegex = regexp.MustCompile(`^bytes(\d+)$`)
+	eip712NameRegex  = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// eip712DomainFields is the canonical order of the EIP712Domain fields
+var eip712DomainFields = []TypedDataField{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+	{Name: "salt", Type: "bytes32"},
+}
+
+// TypedDataField is a member of an EIP-712 struct type
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is EIP-712 typed structured data, as sent to eth_signTypedData_v4
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]any              `json:"domain"`
+	Message     map[string]any              `json:"message"`
+}
+
+// ParseTypedData parses the JSON form of EIP-712 typed data.
+// Numbers are kept as json.Number so uint256 values do not lose precision.
+func ParseTypedData(typedDataJSON string) (*TypedData, error) {
+	decoder := json.NewDecoder(strings.NewReader(typedDataJSON))
+	decoder.UseNumber()
+
+	var typedData TypedData
+	if err := decoder.Decode(&typedData); err != nil {
+		return nil, err
+	}
+	if typedData.PrimaryType == "" {
+		return nil, errors.New("primaryType is empty")
+	}
+	if typedData.Types == nil {
+		typedData.Types = map[string][]TypedDataField{}
+	}
+	// Wallets usually send EIP712Domain, but it can be derived from the domain itself
+	if _, ok := typedData.Types[eip712DomainType]; !ok {
+		domainFields := []TypedDataField{}
+		for _, field := range eip712DomainFields {
+			if _, ok := typedData.Domain[field.Name]; ok {
+				domainFields = append(domainFields, field)
+			}
+		}
+		typedData.Types[eip712DomainType] = domainFields
+	}
+	if err := typedData.validate(); err != nil {
+		return nil, err
+	}
+	return &typedData, nil
+}
+
+// validate checks that every referenced type is either a struct or a valid atomic/dynamic type
+func (td *TypedData) validate() error {
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return fmt.Errorf("primaryType %q is not defined", td.PrimaryType)
+	}
+	for typeName, fields := range td.Types {
+		if !eip712NameRegex.MatchString(typeName) {
+			return fmt.Errorf("invalid type name %q", typeName)
+		}
+		for _, field := range fields {
+			if field.Name == "" {
+				return fmt.Errorf("type %q has a field without name", typeName)
+			}
+			baseType := eip712BaseType(field.Type)
+			if _, ok := td.Types[baseType]; !ok && !isEIP712PrimitiveType(baseType) {
+				return fmt.Errorf("type %q of %s.%s is not defined", field.Type, typeName, field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeType returns encodeType(primaryType): the primary type followed by its
+// referenced struct types sorted by name, e.g. `Mail(Person from,Person to,string contents)Person(string name,address wallet)`
+func (td *TypedData) EncodeType(primaryType string) string {
+	deps := td.dependencies(primaryType, map[string]bool{})
+	delete(deps, primaryType)
+
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	names = append([]string{primaryType}, names...)
+
+	var buf strings.Builder
+	for _, name := range names {
+		buf.WriteString(name)
+		buf.WriteString("(")
+		for i, field := range td.Types[name] {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(field.Type)
+			buf.WriteString(" ")
+			buf.WriteString(field.Name)
+		}
+		buf.WriteString(")")
+	}
+	return buf.String()
+}
+
+// dependencies collects all struct types reachable from typeName, including itself
+func (td *TypedData) dependencies(typeName string, found map[string]bool) map[string]bool {
+	typeName = eip712BaseType(typeName)
+	if found[typeName] {
+		return found
+	}
+	fields, ok := td.Types[typeName]
+	if !ok {
+		return found
+	}
+	found[typeName] = true
+	for _, field := range fields {
+		td.dependencies(field.Type, found)
+	}
+	return found
+}
+
+// TypeHash returns keccak256(encodeType(primaryType))
+func (td *TypedData) TypeHash(primaryType string) []byte {
+	return crypto.Keccak256([]byte(td.EncodeType(primaryType)))
+}
+
+// EncodeData returns typeHash ‖ enc(value₁) ‖ … ‖ enc(valueₙ) for a struct value
+func (td *TypedData) EncodeData(primaryType string, data map[string]any) ([]byte, error) {
+	fields, ok := td.Types[primaryType]
+	if !ok {
+		return nil, fmt.Errorf("type %q is not defined", primaryType)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(td.TypeHash(primaryType))
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for %s.%s", primaryType, field.Name)
+		}
+		encoded, err := td.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", primaryType, field.Name, err)
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// HashStruct returns keccak256(encodeData(primaryType, data))
+func (td *TypedData) HashStruct(primaryType string, data map[string]any) ([]byte, error) {
+	encoded, err := td.EncodeData(primaryType, data)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(encoded), nil
+}
+
+// DomainSeparator returns hashStruct(eip712Domain)
+func (td *TypedData) DomainSeparator() ([]byte, error) {
+	return td.HashStruct(eip712DomainType, td.Domain)
+}
+
+// SignHash returns keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)),
+// the digest that is actually signed by the wallet.
+func (td *TypedData) SignHash() ([]byte, error) {
+	domainSeparator, err := td.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	messageHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, messageHash), nil
+}
+
+// encodeValue encodes a single member value into 32 bytes
+func (td *TypedData) encodeValue(encType string, value any) ([]byte, error) {
+	// Arrays: keccak256 of the concatenated encodings of the items
+	if matches := eip712ArrayRegex.FindStringSubmatch(encType); matches != nil {
+		items, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not an array of %s", value, encType)
+		}
+		if matches[2] != "" {
+			size, _ := strconv.Atoi(matches[2])
+			if len(items) != size {
+				return nil, fmt.Errorf("array %s expects %d items, got %d", encType, size, len(items))
+			}
+		}
+		var buf bytes.Buffer
+		for _, item := range items {
+			encoded, err := td.encodeValue(matches[1], item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(encoded)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+
+	// Structs: hashStruct of the nested value
+	if _, ok := td.Types[encType]; ok {
+		data, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not a %s struct", value, encType)
+		}
+		return td.HashStruct(encType, data)
+	}
+
+	return encodeEIP712Primitive(encType, value)
+}
+
+// encodeEIP712Primitive encodes atomic and dynamic (bytes, string) values
+func encodeEIP712Primitive(encType string, value any) ([]byte, error) {
+	switch encType {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not a string", value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+	case "bytes":
+		raw, err := eip712Bytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(raw), nil
+	case "bool":
+		var b bool
+		switch v := value.(type) {
+		case bool:
+			b = v
+		case string:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("value %v is not a bool", value)
+			}
+			b = parsed
+		default:
+			return nil, fmt.Errorf("value %v is not a bool", value)
+		}
+		if b {
+			return math.U256Bytes(big.NewInt(1)), nil
+		}
+		return make([]byte, 32), nil
+	case "address":
+		str, ok := value.(string)
+		if !ok || !common.IsHexAddress(str) {
+			return nil, fmt.Errorf("value %v is not an address", value)
+		}
+		return common.LeftPadBytes(common.HexToAddress(str).Bytes(), 32), nil
+	}
+
+	if matches := eip712BytesRegex.FindStringSubmatch(encType); matches != nil {
+		size, _ := strconv.Atoi(matches[1])
+		raw, err := eip712Bytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) > size {
+			return nil, fmt.Errorf("value %v is longer than %s", value, encType)
+		}
+		return common.RightPadBytes(raw, 32), nil
+	}
+
+	if matches := eip712IntRegex.FindStringSubmatch(encType); matches != nil {
+		bits := 256
+		if matches[2] != "" {
+			bits, _ = strconv.Atoi(matches[2])
+		}
+		n, err := eip712Integer(value)
+		if err != nil {
+			return nil, err
+		}
+		if matches[1] == "uint" {
+			if n.Sign() < 0 || n.BitLen() > bits {
+				return nil, fmt.Errorf("value %v overflows %s", value, encType)
+			}
+			return math.U256Bytes(new(big.Int).Set(n)), nil
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("value %v overflows %s", value, encType)
+		}
+		return math.U256Bytes(new(big.Int).Set(n)), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %q", encType)
+}
+
+// eip712Bytes accepts 0x-prefixed hex strings and raw byte slices
+func eip712Bytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if !strings.HasPrefix(v, "0x") && !strings.HasPrefix(v, "0X") {
+			return nil, fmt.Errorf("value %v is not 0x-prefixed hex", value)
+		}
+		raw, err := hex.DecodeString(v[2:])
+		if err != nil {
+			return nil, fmt.Errorf("value %v is not hex: %w", value, err)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("value %v is not bytes", value)
+}
+
+// eip712Integer accepts JSON numbers, decimal strings and 0x-prefixed hex strings
+func eip712Integer(value any) (*big.Int, error) {
+	var str string
+	switch v := value.(type) {
+	case json.Number:
+		str = v.String()
+	case string:
+		str = v
+	case float64:
+		str = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case *big.Int:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("value %v is not an integer", value)
+	}
+	n, ok := math.ParseBig256(str)
+	if !ok {
+		// ParseBig256 rejects negative numbers
+		n, ok = new(big.Int).SetString(str, 10)
+		if !ok {
+			return nil, fmt.Errorf("value %v is not an integer", value)
+		}
+	}
+	return n, nil
+}
+
+// eip712BaseType strips all array suffixes, e.g. Person[2][] => Person
+func eip712BaseType(encType string) string {
+	if i := strings.Index(encType, "["); i > -1 {
+		return encType[:i]
+	}
+	return encType
+}
+
+// isEIP712PrimitiveType reports whether encType is an atomic or dynamic type
+func isEIP712PrimitiveType(encType string) bool {
+	switch encType {
+	case "string", "bytes", "bool", "address":
+		return true
+	}
+	if matches := eip712BytesRegex.FindStringSubmatch(encType); matches != nil {
+		size, _ := strconv.Atoi(matches[1])
+		return size >= 1 && size <= 32
+	}
+	if matches := eip712IntRegex.FindStringSubmatch(encType); matches != nil {
+		if matches[2] == "" {
+			return true
+		}
+		bits, _ := strconv.Atoi(matches[2])
+		return bits >= 8 && bits <= 256 && bits%8 == 0
+	}
+	return false
+}
+
+// TypedDataHash returns the EIP-712 digest of the typed data JSON
+func TypedDataHash(typedDataJSON string) ([]byte, error) {
+	typedData, err := ParseTypedData(typedDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	return typedData.SignHash()
+}
+
+// VerifyTypedData verifies that the EIP-712 signature of typedDataJSON corresponds to the given address.
+func VerifyTypedData(address, typedDataJSON, signature string) (common.Address, error) {
+	address1 := common.HexToAddress(address)
+	rawSig := common.FromHex(signature)
+
+	if len(rawSig) != 65 { // Ethereum signatures are 65 bytes
+		return common.Address{}, errors.New("bad signature length")
+	}
+
+	// Accept both 27/28 and 0/1 recovery IDs
+	if rawSig[64] >= 27 {
+		rawSig[64] -= 27
+	}
+
+	hash, err := TypedDataHash(typedDataJSON)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	publicKey, err := crypto.SigToPub(hash, rawSig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	if owner := crypto.PubkeyToAddress(*publicKey); owner != address1 {
+		return common.Address{}, errors.New("mismatch")
+	}
+
+	return address1, nil
+}
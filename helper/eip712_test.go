@@ -0,0 +1,199 @@
+package helper
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mailTypedData is the example from https://eips.ethereum.org/EIPS/eip-712 (assets/eip-712/Example.js)
+const mailTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallet", "type": "address"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+    "to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+    "contents": "Hello, Bob!"
+  }
+}`
+
+// mailArrayTypedData is the eth_signTypedData_v4 example with arrays of structs and addresses
+const mailArrayTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Group": [
+      {"name": "name", "type": "string"},
+      {"name": "members", "type": "Person[]"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person[]"},
+      {"name": "contents", "type": "string"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallets", "type": "address[]"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {
+      "name": "Cow",
+      "wallets": ["0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", "0xDeaDbeefdEAdbeefdEadbEEFdeadbeEFdEaDbeeF"]
+    },
+    "to": [{
+      "name": "Bob",
+      "wallets": ["0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", "0xB0BdaBea57B0BDABeA57b0bdABEA57b0BDabEa57", "0xB0B0b0b0b0b0B000000000000000000000000000"]
+    }],
+    "contents": "Hello, Bob!"
+  }
+}`
+
+func TestTypedDataReferenceVectors(t *testing.T) {
+	typedData, err := ParseTypedData(mailTypedData)
+	if err != nil {
+		t.Fatalf("ParseTypedData() error = %v", err)
+	}
+
+	if got, want := typedData.EncodeType("Mail"), "Mail(Person from,Person to,string contents)Person(string name,address wallet)"; got != want {
+		t.Errorf("EncodeType() = %s, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(typedData.TypeHash("Mail")), "a0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"; got != want {
+		t.Errorf("TypeHash() = %s, want %s", got, want)
+	}
+
+	messageHash, err := typedData.HashStruct("Mail", typedData.Message)
+	if err != nil {
+		t.Fatalf("HashStruct() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(messageHash), "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"; got != want {
+		t.Errorf("HashStruct() = %s, want %s", got, want)
+	}
+
+	domainSeparator, err := typedData.DomainSeparator()
+	if err != nil {
+		t.Fatalf("DomainSeparator() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(domainSeparator), "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"; got != want {
+		t.Errorf("DomainSeparator() = %s, want %s", got, want)
+	}
+
+	hash, err := typedData.SignHash()
+	if err != nil {
+		t.Fatalf("SignHash() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(hash), "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; got != want {
+		t.Errorf("SignHash() = %s, want %s", got, want)
+	}
+}
+
+func TestVerifyTypedData(t *testing.T) {
+	// v = 28, r and s from the EIP example, signed with keccak256("cow")
+	signature := "0x" +
+		"4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" +
+		"1c"
+
+	if _, err := VerifyTypedData("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", mailTypedData, signature); err != nil {
+		t.Errorf("VerifyTypedData() error = %v", err)
+	}
+	if _, err := VerifyTypedData("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", mailTypedData, signature); err == nil {
+		t.Errorf("VerifyTypedData() should fail for the wrong address")
+	}
+	if _, err := VerifyTypedData("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", mailTypedData, "0x1234"); err == nil {
+		t.Errorf("VerifyTypedData() should fail for a bad signature length")
+	}
+}
+
+func TestTypedDataArrays(t *testing.T) {
+	typedData, err := ParseTypedData(mailArrayTypedData)
+	if err != nil {
+		t.Fatalf("ParseTypedData() error = %v", err)
+	}
+
+	// Group is not referenced by Mail, so it must not appear in encodeType
+	if got, want := typedData.EncodeType("Mail"), "Mail(Person from,Person[] to,string contents)Person(string name,address[] wallets)"; got != want {
+		t.Errorf("EncodeType() = %s, want %s", got, want)
+	}
+	if got, want := typedData.EncodeType("Group"), "Group(string name,Person[] members)Person(string name,address[] wallets)"; got != want {
+		t.Errorf("EncodeType() = %s, want %s", got, want)
+	}
+
+	hash, err := typedData.SignHash()
+	if err != nil {
+		t.Fatalf("SignHash() error = %v", err)
+	}
+	if got, want := hex.EncodeToString(hash), "a85c2e2b118698e88db68a8105b794a8cc7cec074e89ef991cb4f5f533819cc2"; got != want {
+		t.Errorf("SignHash() = %s, want %s", got, want)
+	}
+
+	// Round trip with a freshly signed digest, using a 0/1 recovery ID
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("cow")))
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyTypedData(crypto.PubkeyToAddress(key.PublicKey).Hex(), mailArrayTypedData, hex.EncodeToString(sig)); err != nil {
+		t.Errorf("VerifyTypedData() error = %v", err)
+	}
+}
+
+func TestTypedDataErrors(t *testing.T) {
+	cases := map[string]string{
+		"undefined type": `{"types":{"Mail":[{"name":"from","type":"Human"}]},"primaryType":"Mail","domain":{"name":"x"},"message":{}}`,
+		"no primaryType": `{"types":{"Mail":[]},"domain":{},"message":{}}`,
+		"bad bytes size": `{"types":{"Mail":[{"name":"a","type":"bytes33"}]},"primaryType":"Mail","domain":{},"message":{}}`,
+	}
+	for name, data := range cases {
+		if _, err := ParseTypedData(data); err == nil {
+			t.Errorf("%s: ParseTypedData() should fail", name)
+		}
+	}
+
+	overflow := `{"types":{"Mail":[{"name":"n","type":"uint8"}]},"primaryType":"Mail","domain":{"name":"x"},"message":{"n":256}}`
+	if _, err := TypedDataHash(overflow); err == nil {
+		t.Errorf("TypedDataHash() should fail for uint8 overflow")
+	}
+	fixed := `{"types":{"Mail":[{"name":"n","type":"int8[2]"}]},"primaryType":"Mail","domain":{"name":"x"},"message":{"n":[-128, 127]}}`
+	if _, err := TypedDataHash(fixed); err != nil {
+		t.Errorf("TypedDataHash() error = %v", err)
+	}
+	fixed = `{"types":{"Mail":[{"name":"n","type":"int8[2]"}]},"primaryType":"Mail","domain":{"name":"x"},"message":{"n":[1]}}`
+	if _, err := TypedDataHash(fixed); err == nil {
+		t.Errorf("TypedDataHash() should fail for a fixed array of the wrong size")
+	}
+}
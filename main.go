@@ -3,6 +3,7 @@ package main
 //go:generate easyi18n extract . ./locales/en.json
 //go:generate easyi18n update -f ./locales/en.json ./locales/zh-hans.json
 //go:generate easyi18n update -f ./locales/en.json ./locales/zh-hant.json
+//go:generate codetool validate_locales ./locales
 //go:generate easyi18n generate --pkg=catalog ./locales ./catalog/main.go
 
 import (
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	tele "gopkg.in/telebot.v4"
+)
+
+func TestTelegramWebhook(t *testing.T) {
+	bot, err := tele.NewBot(tele.Settings{Token: "123:secret-token", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan string, 8)
+	release := make(chan struct{})
+	bot.Handle(tele.OnText, func(c tele.Context) error {
+		if c.Text() == "block" {
+			<-release
+		}
+		handled <- c.Text()
+		return nil
+	})
+
+	config := &TelegramWebhookConfig{
+		Bot: func(botID int64) *tele.Bot {
+			if botID == 123 {
+				return bot
+			}
+			return nil
+		},
+		SecretToken: func(botID int64) string { return "s3cret" },
+		Workers:     1,
+		QueueSize:   1,
+	}
+	defer config.Close()
+	e := echo.New()
+	e.POST("/webhook/:token", TelegramWebhookHandler(config), TelegramWebhook(config))
+
+	post := func(param, secret, text string) int {
+		body := fmt.Sprintf(`{"update_id":1,"message":{"message_id":1,"text":%q,"chat":{"id":1,"type":"private"},"from":{"id":1}}}`, text)
+		req := httptest.NewRequest(http.MethodPost, "/webhook/"+param, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(TelegramSecretTokenHeader, secret)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tt := range []struct {
+		name, param, secret string
+		want                int
+	}{
+		{"bad secret", "123", "wrong", http.StatusUnauthorized},
+		{"missing secret", "123", "", http.StatusUnauthorized},
+		{"unknown bot", "456", "s3cret", http.StatusNotFound},
+		{"token mismatch", "bot123:other-token", "s3cret", http.StatusNotFound},
+		{"not a bot", "webhook", "s3cret", http.StatusNotFound},
+	} {
+		if code := post(tt.param, tt.secret, "hello"); code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	select {
+	case text := <-handled:
+		t.Errorf("rejected update %q was processed", text)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for _, param := range []string{"123", "bot123:secret-token"} {
+		if code := post(param, "s3cret", "hello"); code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", param, code)
+		}
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: update was not processed", param)
+		}
+	}
+
+	// 唯一的 worker 阻塞，队列满后响应 503
+	if code := post("123", "s3cret", "block"); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(config.pool.jobs) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if code := post("123", "s3cret", "queued"); code != http.StatusOK {
+		t.Errorf("status = %d, want 200", code)
+	}
+	if code := post("123", "s3cret", "dropped"); code != http.StatusServiceUnavailable {
+		t.Errorf("queue full: status = %d, want 503", code)
+	}
+	close(release)
+	for _, want := range []string{"block", "queued"} {
+		select {
+		case text := <-handled:
+			if text != want {
+				t.Errorf("processed %q, want %q", text, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("update %q was not processed", want)
+		}
+	}
+
+	// 关闭后不再接收
+	config.Close()
+	if code := post("123", "s3cret", "closed"); code != http.StatusServiceUnavailable {
+		t.Errorf("after Close: status = %d, want 503", code)
+	}
+}
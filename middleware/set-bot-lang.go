@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	"github.com/mylukin/easy-i18n/i18n"
+	"golang.org/x/text/language"
+	tele "gopkg.in/telebot.v4"
+)
+
+type (
+	// SetBotLangConfig is config
+	SetBotLangConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper func(c tele.Context) bool
+		// 获取用户设置的语言，如 RedisBotLanguage / MongoBotLanguage
+		UserLanguage func(c tele.Context) string
+		// 获取群组默认语言
+		ChatLanguage func(c tele.Context) string
+		// support languages
+		Languages []language.Tag
+	}
+)
+
+// SetBotLang set language for telebot context, like SetLang does for echo.
+//
+// The language is resolved in order from:
+//  1. UserLanguage, the preference saved by the user
+//  2. Sender().LanguageCode, the Telegram client language
+//  3. ChatLanguage, the default language of the chat
+//  4. LANGUAGE env
+func SetBotLang(config SetBotLangConfig) tele.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = func(c tele.Context) bool { return false }
+	}
+	var matcher language.Matcher
+	if len(config.Languages) > 0 {
+		matcher = language.NewMatcher(config.Languages)
+	}
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			var accept string
+			// 从函数里获取用户的语言设置
+			if config.UserLanguage != nil {
+				accept = config.UserLanguage(c)
+			}
+			// 获取 Telegram 客户端的语言
+			if accept == "" && c.Sender() != nil {
+				accept = c.Sender().LanguageCode
+			}
+			// 获取群组默认语言
+			if accept == "" && config.ChatLanguage != nil {
+				accept = config.ChatLanguage(c)
+			}
+			// 获取不到语言设置，执行默认的语言设置
+			if accept == "" {
+				accept = helper.Config("LANGUAGE")
+			}
+
+			var tag language.Tag
+			if matcher != nil {
+				tag, _ = language.MatchStrings(matcher, accept)
+			} else {
+				tag = language.Make(accept)
+			}
+
+			// 设置环境变量
+			c.Set("Language", i18n.NewPrinter(normalizeLang(tag)))
+
+			return next(c)
+		}
+	}
+}
+
+// RedisBotLanguage read the user language from redis, key is a format with the user ID, e.g. "user:lang:%d"
+func RedisBotLanguage(key string) func(c tele.Context) string {
+	return func(c tele.Context) string {
+		if c.Sender() == nil {
+			return ""
+		}
+		lang, err := redis.Get(fmt.Sprintf(key, c.Sender().ID)).Result()
+		if err != nil {
+			return ""
+		}
+		return lang
+	}
+}
+
+// MongoBotLanguage read the user language from the langField of the document whose idField is the user ID
+func MongoBotLanguage(collection, idField, langField string) func(c tele.Context) string {
+	return func(c tele.Context) string {
+		if c.Sender() == nil {
+			return ""
+		}
+		var result map[string]any
+		if err := mongo.C(collection).FindByField(idField, c.Sender().ID, &result); err != nil {
+			return ""
+		}
+		lang, _ := result[langField].(string)
+		return lang
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/mylukin/EchoPilot/helper"
-	"github.com/mylukin/easy-i18n/i18n"
+	"github.com/mylukin/EchoPilot/service/i18n"
 	"golang.org/x/text/language"
 )
 
@@ -56,15 +56,12 @@ func SetLang(config SetLangConfig) echo.MiddlewareFunc {
 			}
 
 			// 获取统一格式的语言设置
-			userLang := tag.String()
-			// 如果子语言，则取父级语言
-			if strings.Count(userLang, "-") > 1 {
-				userLang = tag.Parent().String()
-			}
-			// 全部变为小写
-			userLang = strings.ToLower(userLang)
+			userLang := normalizeLang(tag)
 			// 设置环境变量
-			c.Set("Language", i18n.NewPrinter(userLang))
+			printer := i18n.NewPrinter(userLang)
+			c.Set("Language", printer)
+			// 只拿到 context.Context 的代码也能取到 printer
+			c.SetRequest(req.WithContext(i18n.WithPrinter(req.Context(), printer)))
 
 			res.Header().Add("Language", userLang)
 
@@ -72,3 +69,14 @@ func SetLang(config SetLangConfig) echo.MiddlewareFunc {
 		}
 	}
 }
+
+// normalizeLang 获取统一格式的语言设置，如 zh-hans
+func normalizeLang(tag language.Tag) string {
+	userLang := tag.String()
+	// 如果子语言，则取父级语言
+	if strings.Count(userLang, "-") > 1 {
+		userLang = tag.Parent().String()
+	}
+	// 全部变为小写
+	return strings.ToLower(userLang)
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	tele "gopkg.in/telebot.v4"
+)
+
+// TelegramSecretTokenHeader is the header Telegram sends with every webhook request
+const TelegramSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type (
+	// TelegramWebhookConfig is config
+	TelegramWebhookConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper middleware.Skipper
+		// 路由参数名，参数值为 bot token 或 bot ID，默认 token
+		// Example: e.POST("/webhook/:token", ...)
+		Param string `yaml:"param"`
+		// 根据 bot ID 获取 secret token，返回空字符串则不校验
+		SecretToken func(botID int64) string
+		// 根据 bot ID 获取 bot 实例，返回 nil 则响应 404
+		Bot func(botID int64) *tele.Bot
+		// 处理 update 的 worker 数量，默认 16
+		Workers int `yaml:"workers"`
+		// 等待处理的 update 队列长度，默认 1024，队列满时响应 503 让 Telegram 重试
+		QueueSize int `yaml:"queue_size"`
+
+		pool *telegramWebhookPool
+		once sync.Once
+	}
+
+	// telegramWebhookJob is a decoded update waiting for a worker
+	telegramWebhookJob struct {
+		bot    *tele.Bot
+		update tele.Update
+	}
+
+	// telegramWebhookPool is a bounded worker pool
+	telegramWebhookPool struct {
+		jobs      chan telegramWebhookJob
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+)
+
+// TelegramWebhook verifies X-Telegram-Bot-Api-Secret-Token and resolves the bot from the URL.
+// It sets "BotID" and "Bot" in the context.
+func TelegramWebhook(config *TelegramWebhookConfig) echo.MiddlewareFunc {
+	config.init()
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			botID := getWebhookBotID(c.Param(config.Param))
+			if botID == 0 {
+				return echo.ErrNotFound
+			}
+
+			// 校验 secret token
+			if config.SecretToken != nil {
+				secret := config.SecretToken(botID)
+				header := c.Request().Header.Get(TelegramSecretTokenHeader)
+				if secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(header)) != 1 {
+					c.Logger().Warnf("telegram webhook: bad secret token for bot %d", botID)
+					return echo.ErrUnauthorized
+				}
+			}
+
+			bot := config.Bot(botID)
+			if bot == nil {
+				return echo.ErrNotFound
+			}
+			// URL 中带完整 token 时，必须与 bot token 一致
+			if token := strings.TrimPrefix(c.Param(config.Param), "bot"); strings.Contains(token, ":") && token != bot.Token {
+				return echo.ErrNotFound
+			}
+
+			c.Set("BotID", botID)
+			c.Set("Bot", bot)
+
+			return next(c)
+		}
+	}
+}
+
+// TelegramWebhookHandler decodes the update and hands it to the worker pool, answering immediately.
+// Use it together with TelegramWebhook:
+//
+//	config := &middleware.TelegramWebhookConfig{Bot: getBot, SecretToken: getSecret}
+//	e.POST("/webhook/:token", middleware.TelegramWebhookHandler(config), middleware.TelegramWebhook(config))
+//
+// Bots should be created with tele.Settings{Synchronous: true}, otherwise telebot starts
+// a goroutine per update and the pool no longer bounds the concurrency.
+func TelegramWebhookHandler(config *TelegramWebhookConfig) echo.HandlerFunc {
+	config.init()
+	return func(c echo.Context) error {
+		bot, ok := c.Get("Bot").(*tele.Bot)
+		if !ok || bot == nil {
+			return echo.ErrNotFound
+		}
+
+		var update tele.Update
+		if err := json.NewDecoder(c.Request().Body).Decode(&update); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		if !config.pool.dispatch(telegramWebhookJob{bot: bot, update: update}) {
+			c.Logger().Warnf("telegram webhook: queue is full, update %d rejected", update.ID)
+			return echo.ErrServiceUnavailable
+		}
+
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// Close stop the workers, updates still queued are dropped and new ones are
+// answered with 503. Call it when the config is no longer routed.
+func (config *TelegramWebhookConfig) Close() {
+	config.init()
+	config.pool.close()
+}
+
+// init set defaults and start the worker pool once
+func (config *TelegramWebhookConfig) init() {
+	config.once.Do(func() {
+		if config.Skipper == nil {
+			config.Skipper = middleware.DefaultSkipper
+		}
+		if config.Param == "" {
+			config.Param = "token"
+		}
+		if config.Bot == nil {
+			config.Bot = func(botID int64) *tele.Bot { return nil }
+		}
+		if config.Workers <= 0 {
+			config.Workers = 16
+		}
+		if config.QueueSize <= 0 {
+			config.QueueSize = 1024
+		}
+		config.pool = newTelegramWebhookPool(config.Workers, config.QueueSize)
+	})
+}
+
+// newTelegramWebhookPool start workers
+func newTelegramWebhookPool(workers, queueSize int) *telegramWebhookPool {
+	pool := &telegramWebhookPool{
+		jobs: make(chan telegramWebhookJob, queueSize),
+		done: make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+// close stop the workers
+func (pool *telegramWebhookPool) close() {
+	pool.closeOnce.Do(func() {
+		close(pool.done)
+	})
+}
+
+// dispatch queue the job without blocking
+func (pool *telegramWebhookPool) dispatch(job telegramWebhookJob) bool {
+	select {
+	case <-pool.done:
+		return false
+	default:
+	}
+	select {
+	case pool.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// work process jobs until the pool is closed
+func (pool *telegramWebhookPool) work() {
+	for {
+		// 关闭后不再处理队列中的 update
+		select {
+		case <-pool.done:
+			return
+		default:
+		}
+		select {
+		case <-pool.done:
+			return
+		case job := <-pool.jobs:
+			pool.process(job)
+		}
+	}
+}
+
+// process one update, a panicking handler must not kill the worker
+func (pool *telegramWebhookPool) process(job telegramWebhookJob) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Errorf("telegram webhook: update %d panic: %v", job.update.ID, err)
+		}
+	}()
+	job.bot.ProcessUpdate(job.update)
+}
+
+// getWebhookBotID 从路由参数中获取 bot ID，支持 "bot<token>", "<token>" 和 "<id>"
+func getWebhookBotID(param string) int64 {
+	param = strings.TrimPrefix(param, "bot")
+	if strings.Contains(param, ":") {
+		return helper.GetBotID(param)
+	}
+	botID, _ := strconv.ParseInt(param, 10, 64)
+	return botID
+}
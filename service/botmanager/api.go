@@ -0,0 +1,92 @@
+package botmanager
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// addBotRequest is the body of POST /bots
+type addBotRequest struct {
+	Token     string `json:"token"`
+	Language  string `json:"language"`
+	RateLimit int    `json:"rate_limit"`
+}
+
+// Routes register the admin API, protect the group with your own auth middleware
+//
+//	GET    /bots      list running bots
+//	POST   /bots      add a bot, body {"token": "...", "language": "en", "rate_limit": 30}
+//	DELETE /bots/:id  stop a bot and delete it
+//	POST   /bots/:id/start  start a disabled bot
+//	POST   /bots/:id/stop   stop a bot and disable it
+func (m *Manager) Routes(g *echo.Group) {
+	g.GET("/bots", m.listBots)
+	g.POST("/bots", m.addBot)
+	g.DELETE("/bots/:id", m.removeBot)
+	g.POST("/bots/:id/start", m.startBot)
+	g.POST("/bots/:id/stop", m.stopBot)
+}
+
+// listBots GET /bots
+func (m *Manager) listBots(c echo.Context) error {
+	return c.JSON(http.StatusOK, m.List())
+}
+
+// addBot POST /bots
+func (m *Manager) addBot(c echo.Context) error {
+	var req addBotRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	bot, err := m.Add(BotConfig{
+		Token:     req.Token,
+		Language:  req.Language,
+		RateLimit: req.RateLimit,
+	})
+	if err == ErrBadToken {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusCreated, bot.Config)
+}
+
+// removeBot DELETE /bots/:id
+func (m *Manager) removeBot(c echo.Context) error {
+	botID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	if err := m.Remove(botID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// startBot POST /bots/:id/start
+func (m *Manager) startBot(c echo.Context) error {
+	botID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	bot, err := m.Enable(botID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, bot.Config)
+}
+
+// stopBot POST /bots/:id/stop
+func (m *Manager) stopBot(c echo.Context) error {
+	botID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	if err := m.Disable(botID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,339 @@
+package botmanager
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/middleware"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	tele "gopkg.in/telebot.v4"
+)
+
+var (
+	// ErrBadToken is not a bot token
+	ErrBadToken = errors.New("botmanager: bad bot token")
+	// ErrNotFound bot is not running
+	ErrNotFound = errors.New("botmanager: bot not found")
+)
+
+// BotConfig is a bot stored in mongo
+type BotConfig struct {
+	ID    int64  `bson:"_id" json:"id"`
+	Token string `bson:"token" json:"-"`
+	// webhook secret token, generated when empty
+	Secret string `bson:"secret" json:"-"`
+	// default language of the bot
+	Language string `bson:"language" json:"language"`
+	// max updates per chat in RateWindow, zero means the manager default
+	RateLimit int       `bson:"rate_limit" json:"rate_limit"`
+	Disabled  bool      `bson:"disabled" json:"disabled"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// Bot is a running bot
+type Bot struct {
+	*tele.Bot
+	Config BotConfig
+}
+
+// Manager hosts many bots in one process
+type Manager struct {
+	bots       map[int64]*Bot
+	mu         sync.RWMutex
+	collection string
+	webhookURL string
+	apiURL     string
+	rateLimit  int
+	rateWindow time.Duration
+	setup      func(bot *Bot)
+}
+
+type Option func(*Manager)
+
+// WithCollection set the mongo collection of bots, default "bots"
+func WithCollection(collection string) Option {
+	return func(m *Manager) {
+		m.collection = collection
+	}
+}
+
+// WithWebhookURL set the public webhook base URL, each bot gets "<url>/<botID>"
+func WithWebhookURL(url string) Option {
+	return func(m *Manager) {
+		m.webhookURL = strings.TrimRight(url, "/")
+	}
+}
+
+// WithAPIURL set the Bot API server, default https://api.telegram.org
+func WithAPIURL(url string) Option {
+	return func(m *Manager) {
+		m.apiURL = url
+	}
+}
+
+// WithRateLimit set the default updates per chat in window, zero disables it
+func WithRateLimit(limit int, window time.Duration) Option {
+	return func(m *Manager) {
+		m.rateLimit = limit
+		m.rateWindow = window
+	}
+}
+
+// WithSetup register handlers for every bot started, branch on bot.Config for per-bot handlers
+func WithSetup(setup func(bot *Bot)) Option {
+	return func(m *Manager) {
+		m.setup = setup
+	}
+}
+
+// New bot manager
+func New(options ...Option) *Manager {
+	m := &Manager{
+		bots:       map[int64]*Bot{},
+		collection: "bots",
+		rateWindow: time.Minute,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Load start all enabled bots in mongo
+func (m *Manager) Load() error {
+	var configs []BotConfig
+	if err := mongo.C(m.collection).WhereField("disabled", bson.M{"$ne": true}).Find(&configs); err != nil {
+		return err
+	}
+	for _, config := range configs {
+		if _, err := m.Start(config); err != nil {
+			log.Errorf("botmanager: start bot %d: %v", config.ID, err)
+		}
+	}
+	return nil
+}
+
+// Add save the bot to mongo and start it
+func (m *Manager) Add(config BotConfig) (*Bot, error) {
+	if !helper.IsBotToken(config.Token) {
+		return nil, ErrBadToken
+	}
+	config.ID = helper.GetBotID(config.Token)
+	config.Disabled = false
+	if config.Secret == "" {
+		config.Secret = newSecret()
+	}
+	if config.CreatedAt.IsZero() {
+		config.CreatedAt = time.Now()
+	}
+
+	bot, err := m.Start(config)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{{Key: "_id", Value: config.ID}}
+	if _, err := mongo.C(m.collection).ReplaceOne(filter, config, options.Replace().SetUpsert(true)); err != nil {
+		m.Stop(config.ID)
+		return nil, err
+	}
+	return bot, nil
+}
+
+// Remove stop the bot and delete it from mongo
+func (m *Manager) Remove(botID int64) error {
+	if err := m.Stop(botID); err != nil && err != ErrNotFound {
+		return err
+	}
+	return mongo.C(m.collection).WhereField("_id", botID).Delete()
+}
+
+// Enable mark the bot enabled in mongo and start it
+func (m *Manager) Enable(botID int64) (*Bot, error) {
+	var config BotConfig
+	if err := mongo.C(m.collection).FindByField("_id", botID, &config); err != nil {
+		return nil, err
+	}
+	bot, err := m.Start(config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mongo.C(m.collection).WhereField("_id", botID).Set(bson.M{"disabled": false}); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// Disable stop the bot and mark it disabled in mongo, so Load skips it
+func (m *Manager) Disable(botID int64) error {
+	if err := m.Stop(botID); err != nil && err != ErrNotFound {
+		return err
+	}
+	_, err := mongo.C(m.collection).WhereField("_id", botID).Set(bson.M{"disabled": true})
+	return err
+}
+
+// Start the bot and set its webhook, a running bot with the same ID is replaced
+func (m *Manager) Start(config BotConfig) (*Bot, error) {
+	if !helper.IsBotToken(config.Token) {
+		return nil, ErrBadToken
+	}
+	config.ID = helper.GetBotID(config.Token)
+	if config.Secret == "" {
+		config.Secret = newSecret()
+	}
+
+	teleBot, err := tele.NewBot(tele.Settings{
+		URL:         m.apiURL,
+		Token:       config.Token,
+		Synchronous: true,
+		OnError: func(err error, c tele.Context) {
+			log.Errorf("botmanager: bot %d: %s", config.ID, maskError(err))
+		},
+	})
+	if err != nil {
+		return nil, errors.New(maskError(err))
+	}
+	bot := &Bot{Bot: teleBot, Config: config}
+
+	bot.Use(m.rateLimiter(bot))
+	bot.Use(middleware.SetBotLang(middleware.SetBotLangConfig{
+		ChatLanguage: func(c tele.Context) string { return bot.Config.Language },
+	}))
+	if m.setup != nil {
+		m.setup(bot)
+	}
+
+	if m.webhookURL != "" {
+		if err := bot.SetWebhook(&tele.Webhook{
+			SecretToken: config.Secret,
+			Endpoint:    &tele.WebhookEndpoint{PublicURL: m.WebhookURL(config.ID)},
+		}); err != nil {
+			return nil, errors.New(maskError(err))
+		}
+	}
+
+	m.mu.Lock()
+	m.bots[config.ID] = bot
+	m.mu.Unlock()
+
+	log.Infof("botmanager: bot %d started, token %s", config.ID, maskToken(config.Token))
+	return bot, nil
+}
+
+// Stop the bot and remove its webhook
+func (m *Manager) Stop(botID int64) error {
+	m.mu.Lock()
+	bot, ok := m.bots[botID]
+	delete(m.bots, botID)
+	m.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	if m.webhookURL != "" {
+		if err := bot.RemoveWebhook(); err != nil {
+			log.Warnf("botmanager: remove webhook of bot %d: %s", botID, maskError(err))
+		}
+	}
+	log.Infof("botmanager: bot %d stopped, token %s", botID, maskToken(bot.Token))
+	return nil
+}
+
+// Get a running bot, nil if not running
+func (m *Manager) Get(botID int64) *Bot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.bots[botID]
+}
+
+// List running bots ordered by ID
+func (m *Manager) List() []BotConfig {
+	m.mu.RLock()
+	configs := make([]BotConfig, 0, len(m.bots))
+	for _, bot := range m.bots {
+		configs = append(configs, bot.Config)
+	}
+	m.mu.RUnlock()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].ID < configs[j].ID })
+	return configs
+}
+
+// WebhookURL of the bot
+func (m *Manager) WebhookURL(botID int64) string {
+	return fmt.Sprintf("%s/%d", m.webhookURL, botID)
+}
+
+// WebhookConfig for middleware.TelegramWebhook, route it as e.POST("/webhook/:id", ...)
+func (m *Manager) WebhookConfig() *middleware.TelegramWebhookConfig {
+	return &middleware.TelegramWebhookConfig{
+		Param: "id",
+		Bot: func(botID int64) *tele.Bot {
+			if bot := m.Get(botID); bot != nil {
+				return bot.Bot
+			}
+			return nil
+		},
+		SecretToken: func(botID int64) string {
+			if bot := m.Get(botID); bot != nil {
+				return bot.Config.Secret
+			}
+			return ""
+		},
+	}
+}
+
+// rateLimiter drop updates of a chat over the limit of the bot
+func (m *Manager) rateLimiter(bot *Bot) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			limit := bot.Config.RateLimit
+			if limit == 0 {
+				limit = m.rateLimit
+			}
+			if limit <= 0 || c.Chat() == nil {
+				return next(c)
+			}
+			allowed, err := redis.RateLimit(fmt.Sprintf("bot:%d:%d", bot.Config.ID, c.Chat().ID), limit, m.rateWindow)
+			if err != nil {
+				log.Warnf("botmanager: rate limit of bot %d: %v", bot.Config.ID, err)
+				return next(c)
+			}
+			if !allowed {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// newSecret webhook secret token, only A-Z, a-z, 0-9, _ and - are allowed by Telegram
+func newSecret() string {
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// maskToken hide the token by HiddenBotToken, e.g. 123456:***
+func maskToken(token string) string {
+	return strings.Trim(helper.HiddenBotToken("/"+token+"/"), "/")
+}
+
+// maskError hide tokens in the request URLs of telebot errors
+func maskError(err error) string {
+	text := helper.HiddenBotToken(err.Error())
+	if token, ok := helper.GetBotToken(text); ok {
+		text = strings.ReplaceAll(text, token, maskToken(token))
+	}
+	return text
+}
@@ -0,0 +1,83 @@
+package botmanager
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1"
+
+func TestMask(t *testing.T) {
+	if got := maskToken(testToken); strings.Contains(got, "AAHdq") || !strings.HasPrefix(got, "123456789:") {
+		t.Errorf("maskToken() = %s", got)
+	}
+
+	err := errors.New(`Post "https://api.telegram.org/bot` + testToken + `/getMe": dial tcp: i/o timeout`)
+	if got := maskError(err); strings.Contains(got, "AAHdq") {
+		t.Errorf("maskError() = %s", got)
+	}
+	if got := maskError(errors.New("token " + testToken + " is invalid")); strings.Contains(got, "AAHdq") {
+		t.Errorf("maskError() = %s", got)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	var mu sync.Mutex
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:])
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			w.Write([]byte(`{"ok":true,"result":{"id":123456789,"is_bot":true,"first_name":"Test","username":"test_bot"}}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	m := New(WithAPIURL(server.URL), WithWebhookURL("https://example.com/webhook/"))
+	if _, err := m.Start(BotConfig{Token: "bad"}); err != ErrBadToken {
+		t.Errorf("Start() error = %v, want ErrBadToken", err)
+	}
+
+	bot, err := m.Start(BotConfig{Token: testToken, Language: "zh-Hans"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bot.Config.ID != 123456789 || bot.Config.Secret == "" {
+		t.Errorf("Start() config = %+v", bot.Config)
+	}
+	if got := m.WebhookURL(bot.Config.ID); got != "https://example.com/webhook/123456789" {
+		t.Errorf("WebhookURL() = %s", got)
+	}
+
+	config := m.WebhookConfig()
+	if config.Bot(123456789) != bot.Bot || config.SecretToken(123456789) != bot.Config.Secret {
+		t.Errorf("WebhookConfig() does not resolve the running bot")
+	}
+	if len(m.List()) != 1 {
+		t.Errorf("List() = %v", m.List())
+	}
+
+	if err := m.Stop(123456789); err != nil {
+		t.Fatal(err)
+	}
+	if m.Get(123456789) != nil || config.Bot(123456789) != nil {
+		t.Errorf("bot is still running after Stop()")
+	}
+	if err := m.Stop(123456789); err != ErrNotFound {
+		t.Errorf("Stop() error = %v, want ErrNotFound", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(methods, ","); got != "getMe,setWebhook,deleteWebhook" {
+		t.Errorf("called %s", got)
+	}
+}
@@ -0,0 +1,503 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/service/i18n"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	goredis "github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Status of a broadcast
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusPaused    Status = "paused"
+	StatusCancelled Status = "cancelled"
+	StatusDone      Status = "done"
+)
+
+// ErrFinished the broadcast is done or cancelled
+var ErrFinished = errors.New("broadcast: already finished")
+
+// queueTTL the queue is dropped when no Run touches it for this long
+const queueTTL = 7 * 24 * time.Hour
+
+// Broadcast is a broadcast stored in mongo
+type Broadcast struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	BotID     int64              `bson:"bot_id" json:"bot_id"`
+	Template  string             `bson:"template" json:"template"`
+	Args      []any              `bson:"args" json:"args"`
+	ParseMode string             `bson:"parse_mode" json:"parse_mode"`
+	Status    Status             `bson:"status" json:"status"`
+	Total     int64              `bson:"total" json:"total"`
+	Delivered int64              `bson:"delivered" json:"delivered"`
+	Blocked   int64              `bson:"blocked" json:"blocked"`
+	Failed    int64              `bson:"failed" json:"failed"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Recipient is a chat in the queue
+type Recipient struct {
+	ChatID  int64  `json:"c"`
+	Lang    string `json:"l,omitempty"`
+	Attempt int    `json:"a,omitempty"`
+}
+
+// Service sends broadcasts of one bot
+type Service struct {
+	bot         tele.API
+	botID       int64
+	collection  string
+	rate        int
+	maxAttempts int
+
+	// 429 后全局暂停到这个时间
+	floodUntil atomic.Int64
+}
+
+type Option func(*Service)
+
+// WithCollection set the mongo collection, default "broadcasts"
+func WithCollection(collection string) Option {
+	return func(s *Service) {
+		s.collection = collection
+	}
+}
+
+// WithRate set the global messages per second, default 25, Telegram allows about 30
+func WithRate(rate int) Option {
+	return func(s *Service) {
+		s.rate = rate
+	}
+}
+
+// WithMaxAttempts set how many times a failed send is tried, default 3
+func WithMaxAttempts(n int) Option {
+	return func(s *Service) {
+		s.maxAttempts = n
+	}
+}
+
+// New broadcast service of bot
+func New(bot *tele.Bot, options ...Option) *Service {
+	s := &Service{
+		bot:         bot,
+		botID:       helper.GetBotID(bot.Token),
+		collection:  "broadcasts",
+		rate:        25,
+		maxAttempts: 3,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.rate <= 0 {
+		s.rate = 1
+	}
+	return s
+}
+
+// Create save the broadcast and queue the recipients, the template is rendered by
+// i18n.Sprintf in each recipient's language. Start sending with Run.
+func (s *Service) Create(template string, args []any, parseMode tele.ParseMode, recipients []Recipient) (*Broadcast, error) {
+	b := &Broadcast{
+		ID:        primitive.NewObjectID(),
+		BotID:     s.botID,
+		Template:  template,
+		Args:      args,
+		ParseMode: string(parseMode),
+		Status:    StatusPending,
+		Total:     int64(len(recipients)),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if _, err := mongo.C(s.collection).Insert(b); err != nil {
+		return nil, err
+	}
+
+	// 分批写入队列
+	ctx := context.Background()
+	key := redis.GetCacheKey(queueKey(b.ID))
+	for start := 0; start < len(recipients); start += 1000 {
+		end := min(start+1000, len(recipients))
+		members := make([]goredis.Z, 0, end-start)
+		for _, recipient := range recipients[start:end] {
+			data, err := json.Marshal(recipient)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, goredis.Z{Score: 0, Member: data})
+		}
+		if err := redis.GetRedis().ZAdd(ctx, key, members...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	if err := redis.GetRedis().Expire(ctx, key, queueTTL).Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Get the broadcast with its counters
+func (s *Service) Get(id primitive.ObjectID) (*Broadcast, error) {
+	b := &Broadcast{}
+	if err := mongo.C(s.collection).FindByID(id, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Pause sending, Run keeps waiting until Resume or Cancel
+func (s *Service) Pause(id primitive.ObjectID) error {
+	return s.setStatus(id, StatusPaused, StatusPending, StatusRunning)
+}
+
+// Resume a paused broadcast
+func (s *Service) Resume(id primitive.ObjectID) error {
+	return s.setStatus(id, StatusRunning, StatusPaused)
+}
+
+// Cancel the broadcast, the remaining recipients are dropped
+func (s *Service) Cancel(id primitive.ObjectID) error {
+	if err := s.setStatus(id, StatusCancelled, StatusPending, StatusRunning, StatusPaused); err != nil {
+		return err
+	}
+	return redis.Del(queueKey(id)).Err()
+}
+
+// setStatus change the status if it is one of from
+func (s *Service) setStatus(id primitive.ObjectID, status Status, from ...Status) error {
+	result, err := mongo.C(s.collection).UpdateOne(
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: bson.M{"$in": from}}},
+		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("broadcast: can't change %s to %s", id.Hex(), status)
+	}
+	return nil
+}
+
+// result of one send
+type result int
+
+const (
+	delivered result = iota
+	blocked
+	failed
+	retry
+)
+
+// counters not yet saved to mongo
+type counters struct {
+	delivered, blocked, failed atomic.Int64
+}
+
+// Run send the broadcast until it is done, cancelled or ctx is done.
+// Run it again after a restart, the queue is kept in redis.
+func (s *Service) Run(ctx context.Context, id primitive.ObjectID) error {
+	b, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if b.Status == StatusDone || b.Status == StatusCancelled {
+		return ErrFinished
+	}
+	if b.Status == StatusPending {
+		if err := s.setStatus(id, StatusRunning, StatusPending); err != nil {
+			return err
+		}
+		b.Status = StatusRunning
+	}
+
+	var wg sync.WaitGroup
+	stats := &counters{}
+	sem := make(chan struct{}, s.rate)
+	ticker := time.NewTicker(time.Second / time.Duration(s.rate))
+	defer ticker.Stop()
+	lastCheck := time.Now()
+
+	defer func() {
+		wg.Wait()
+		s.flush(id, stats)
+	}()
+
+	for {
+		// 每秒保存计数并检查状态
+		if time.Since(lastCheck) >= time.Second {
+			lastCheck = time.Now()
+			s.flush(id, stats)
+			if b, err = s.Get(id); err != nil {
+				return err
+			}
+		}
+		switch b.Status {
+		case StatusCancelled:
+			s.drop(id, &wg)
+			return nil
+		case StatusPaused:
+			if err := sleep(ctx, time.Second); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 遇到 429 时等待 retry_after
+		if wait := time.Until(time.Unix(0, s.floodUntil.Load())); wait > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+
+		recipient, score, err := s.pop(id)
+		if err == redis.RedisNil {
+			// 队列为空，等待正在发送的完成
+			wg.Wait()
+			if n, _ := redis.GetRedis().ZCard(context.Background(), redis.GetCacheKey(queueKey(id))).Result(); n > 0 {
+				continue
+			}
+			s.flush(id, stats)
+			if err := s.setStatus(id, StatusDone, StatusRunning, StatusPaused); err != nil {
+				// 已被取消
+				if b, _ := s.Get(id); b != nil && b.Status == StatusCancelled {
+					s.drop(id, &wg)
+					return nil
+				}
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 重试的消息未到时间
+		if wait := time.Until(time.Unix(int64(score), 0)); wait > 0 {
+			s.push(id, recipient, score)
+			if err := sleep(ctx, min(wait, 100*time.Millisecond)); err != nil {
+				return err
+			}
+			continue
+		}
+		// 同一个聊天的发送间隔
+		if !s.acquireChat(recipient.ChatID) {
+			s.push(id, recipient, float64(time.Now().Add(chatInterval(recipient.ChatID)).Unix()))
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			s.push(id, recipient, score)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(b *Broadcast, recipient Recipient) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			switch s.send(b, &recipient) {
+			case delivered:
+				stats.delivered.Add(1)
+			case blocked:
+				stats.blocked.Add(1)
+			case failed:
+				stats.failed.Add(1)
+			}
+		}(b, recipient)
+	}
+}
+
+// send one message, requeue it on 429 or a temporary error
+func (s *Service) send(b *Broadcast, recipient *Recipient) result {
+	lang := recipient.Lang
+	if lang == "" {
+		lang = helper.Config("LANGUAGE")
+	}
+	text := i18n.Sprintf(i18n.Make(lang), b.Template, b.Args...)
+
+	_, err := s.bot.Send(&tele.Chat{ID: recipient.ChatID}, text, &tele.SendOptions{ParseMode: tele.ParseMode(b.ParseMode)})
+	res, retryAfter := classify(err)
+	switch res {
+	case retry:
+		recipient.Attempt++
+		if recipient.Attempt >= s.maxAttempts {
+			log.Warnf("broadcast %s: chat %d failed: %v", b.ID.Hex(), recipient.ChatID, err)
+			return failed
+		}
+		if retryAfter > 0 {
+			until := time.Now().Add(retryAfter)
+			if until.UnixNano() > s.floodUntil.Load() {
+				s.floodUntil.Store(until.UnixNano())
+			}
+		} else {
+			retryAfter = time.Duration(recipient.Attempt*recipient.Attempt) * time.Second
+		}
+		if s.cancelled(b.ID) {
+			// 已取消的广播不再入队，否则队列会被重新创建
+			return failed
+		}
+		s.push(b.ID, *recipient, float64(time.Now().Add(retryAfter).Unix()))
+	case failed:
+		log.Warnf("broadcast %s: chat %d failed: %v", b.ID.Hex(), recipient.ChatID, err)
+	}
+	return res
+}
+
+// classify the send error
+func classify(err error) (result, time.Duration) {
+	if err == nil {
+		return delivered, 0
+	}
+
+	var flood tele.FloodError
+	if errors.As(err, &flood) {
+		return retry, time.Duration(flood.RetryAfter) * time.Second
+	}
+	for _, e := range []error{
+		tele.ErrBlockedByUser,
+		tele.ErrUserIsDeactivated,
+		tele.ErrNotStartedByUser,
+		tele.ErrKickedFromGroup,
+		tele.ErrKickedFromSuperGroup,
+		tele.ErrKickedFromChannel,
+		tele.ErrChatNotFound,
+	} {
+		if errors.Is(err, e) {
+			return blocked, 0
+		}
+	}
+	code := 0
+	var apiErr *tele.Error
+	if errors.As(err, &apiErr) {
+		code = apiErr.Code
+	} else if m := apiErrorRegex.FindStringSubmatch(err.Error()); m != nil {
+		// telebot 未定义的错误，如 "telegram: Bad Request: can't parse entities (400)"
+		code, _ = strconv.Atoi(m[1])
+	}
+	switch {
+	case code == 0 || code >= 500:
+		// 网络错误或服务端错误
+		return retry, 0
+	case code == 403:
+		return blocked, 0
+	}
+	return failed, 0
+}
+
+var apiErrorRegex = regexp.MustCompile(`^telegram: .* \((\d{3})\)$`)
+
+// flush save the counters to mongo
+func (s *Service) flush(id primitive.ObjectID, stats *counters) {
+	inc := bson.M{}
+	if n := stats.delivered.Swap(0); n > 0 {
+		inc["delivered"] = n
+	}
+	if n := stats.blocked.Swap(0); n > 0 {
+		inc["blocked"] = n
+	}
+	if n := stats.failed.Swap(0); n > 0 {
+		inc["failed"] = n
+	}
+	if len(inc) == 0 {
+		return
+	}
+	if _, err := mongo.C(s.collection).UpdateByID(id, bson.M{"$inc": inc, "$set": bson.M{"updated_at": time.Now()}}); err != nil {
+		log.Errorf("broadcast %s: save counters: %v", id.Hex(), err)
+	}
+}
+
+// pop the first recipient
+func (s *Service) pop(id primitive.ObjectID) (Recipient, float64, error) {
+	var recipient Recipient
+	res, err := redis.GetRedis().ZPopMin(context.Background(), redis.GetCacheKey(queueKey(id)), 1).Result()
+	if err != nil {
+		return recipient, 0, err
+	}
+	if len(res) == 0 {
+		return recipient, 0, redis.RedisNil
+	}
+	member, _ := res[0].Member.(string)
+	err = json.Unmarshal([]byte(member), &recipient)
+	return recipient, res[0].Score, err
+}
+
+// push the recipient back, score is the unix time it can be sent
+func (s *Service) push(id primitive.ObjectID, recipient Recipient, score float64) {
+	if err := redis.AddQueueByScore(queueKey(id), recipient, score); err != nil {
+		log.Errorf("broadcast %s: requeue chat %d: %v", id.Hex(), recipient.ChatID, err)
+		return
+	}
+	redis.Expire(queueKey(id), queueTTL)
+}
+
+// cancelled whether the broadcast was cancelled
+func (s *Service) cancelled(id primitive.ObjectID) bool {
+	b, err := s.Get(id)
+	return err == nil && b.Status == StatusCancelled
+}
+
+// drop the queue of a cancelled broadcast once the sends in flight are done,
+// they may have requeued recipients after Cancel deleted it
+func (s *Service) drop(id primitive.ObjectID, wg *sync.WaitGroup) {
+	wg.Wait()
+	if err := redis.Del(queueKey(id)).Err(); err != nil {
+		log.Errorf("broadcast %s: drop queue: %v", id.Hex(), err)
+	}
+}
+
+// acquireChat keep the per-chat limit across broadcasts and processes
+func (s *Service) acquireChat(chatID int64) bool {
+	ok, err := redis.SetNX(fmt.Sprintf("broadcast:chat:%d:%d", s.botID, chatID), 1, chatInterval(chatID)).Result()
+	if err != nil {
+		return true
+	}
+	return ok
+}
+
+// chatInterval 1 message per second in private chats, 20 per minute in groups
+func chatInterval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return 3 * time.Second
+	}
+	return time.Second
+}
+
+// queueKey of the recipients
+func queueKey(id primitive.ObjectID) string {
+	return "broadcast:queue:" + id.Hex()
+}
+
+// sleep or return when ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
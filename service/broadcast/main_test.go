@@ -0,0 +1,214 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/service/fakebot"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	tele "gopkg.in/telebot.v4"
+)
+
+func TestClassify(t *testing.T) {
+	server := fakebot.New()
+	defer server.Close()
+
+	bot, err := tele.NewBot(tele.Settings{URL: server.URL, Token: "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		err        error
+		want       result
+		retryAfter time.Duration
+	}{
+		{nil, delivered, 0},
+		{&fakebot.Error{Code: 429, Description: "Too Many Requests: retry after 7", RetryAfter: 7}, retry, 7 * time.Second},
+		{&fakebot.Error{Code: 403, Description: "Forbidden: bot was blocked by the user"}, blocked, 0},
+		{&fakebot.Error{Code: 403, Description: "Forbidden: user is deactivated"}, blocked, 0},
+		{&fakebot.Error{Code: 400, Description: "Bad Request: chat not found"}, blocked, 0},
+		{&fakebot.Error{Code: 400, Description: "Bad Request: can't parse entities"}, failed, 0},
+		{&fakebot.Error{Code: 502, Description: "Bad Gateway"}, retry, 0},
+	}
+	for _, tc := range cases {
+		server.Handle("sendMessage", func(call fakebot.Call) (any, error) {
+			if tc.err != nil {
+				return nil, tc.err
+			}
+			return tele.Message{ID: 1, Chat: &tele.Chat{ID: 42}}, nil
+		})
+		_, err := bot.Send(&tele.Chat{ID: 42}, "hi")
+		got, retryAfter := classify(err)
+		if got != tc.want || retryAfter != tc.retryAfter {
+			t.Errorf("classify(%v) = %d, %v, want %d, %v", err, got, retryAfter, tc.want, tc.retryAfter)
+		}
+	}
+
+	if got, _ := classify(errors.New("dial tcp: connection refused")); got != retry {
+		t.Errorf("network error should be retried")
+	}
+}
+
+func TestChatInterval(t *testing.T) {
+	if chatInterval(42) != time.Second || chatInterval(-10042) != 3*time.Second {
+		t.Errorf("chatInterval() = %v, %v", chatInterval(42), chatInterval(-10042))
+	}
+}
+
+// newTestService a service sending to fakebot, Run needs mongo and redis
+func newTestService(t *testing.T, options ...Option) (*fakebot.Server, *Service) {
+	if helper.Config("MONGO_URI") == "" || helper.Config("REDIS_SERVERS") == "" {
+		t.Skip("MONGO_URI and REDIS_SERVERS are required")
+	}
+	server := fakebot.New()
+	t.Cleanup(server.Close)
+	bot, err := tele.NewBot(tele.Settings{URL: server.URL, Token: "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1", Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection := "broadcasts_test_" + primitive.NewObjectID().Hex()
+	t.Cleanup(func() { mongo.C(collection).Drop() })
+	return server, New(bot, append([]Option{WithCollection(collection)}, options...)...)
+}
+
+// recipients chat 1..n
+func recipients(n int) []Recipient {
+	list := make([]Recipient, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, Recipient{ChatID: int64(i), Lang: "en"})
+	}
+	return list
+}
+
+func TestRun(t *testing.T) {
+	server, s := newTestService(t, WithRate(50))
+
+	// chat 1 先遇到 429，chat 2 屏蔽了机器人
+	var flooded atomic.Bool
+	server.Handle("sendMessage", func(call fakebot.Call) (any, error) {
+		switch call.Int64("chat_id") {
+		case 1:
+			if !flooded.Swap(true) {
+				return nil, &fakebot.Error{Code: 429, Description: "Too Many Requests: retry after 1", RetryAfter: 1}
+			}
+		case 2:
+			return nil, &fakebot.Error{Code: 403, Description: "Forbidden: bot was blocked by the user"}
+		}
+		return tele.Message{ID: 1, Chat: &tele.Chat{ID: call.Int64("chat_id")}}, nil
+	})
+
+	b, err := s.Create("Hello", nil, tele.ModeDefault, recipients(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.Run(ctx, b.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = s.Get(b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Status != StatusDone || b.Delivered != 4 || b.Blocked != 1 || b.Failed != 0 {
+		t.Errorf("status = %s, delivered %d, blocked %d, failed %d, want done, 4, 1, 0", b.Status, b.Delivered, b.Blocked, b.Failed)
+	}
+	var retried []fakebot.Call
+	for _, call := range server.Calls("sendMessage") {
+		if call.Int64("chat_id") == 1 {
+			retried = append(retried, call)
+		}
+	}
+	if len(retried) != 2 {
+		t.Fatalf("chat 1 called %d times, want 2", len(retried))
+	}
+	if wait := retried[1].Time.Sub(retried[0].Time); wait < 900*time.Millisecond {
+		t.Errorf("retried after %v, want retry_after 1s", wait)
+	}
+	if err := s.Run(ctx, b.ID); err != ErrFinished {
+		t.Errorf("Run() after done = %v, want ErrFinished", err)
+	}
+}
+
+func TestRunPauseCancel(t *testing.T) {
+	server, s := newTestService(t, WithRate(20))
+
+	// chat 50 发送中时取消，之后返回 502
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	server.Handle("sendMessage", func(call fakebot.Call) (any, error) {
+		if call.Int64("chat_id") == 50 {
+			close(inFlight)
+			<-release
+			return nil, &fakebot.Error{Code: 502, Description: "Bad Gateway"}
+		}
+		return tele.Message{ID: 1, Chat: &tele.Chat{ID: call.Int64("chat_id")}}, nil
+	})
+
+	b, err := s.Create("Hello", nil, tele.ModeDefault, recipients(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx, b.ID) }()
+
+	if _, ok := server.WaitCall("sendMessage", 10, 5*time.Second); !ok {
+		t.Fatal("broadcast not started")
+	}
+	if err := s.Pause(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Run 每秒检查一次状态
+	time.Sleep(1500 * time.Millisecond)
+	paused := len(server.Calls("sendMessage"))
+	time.Sleep(1200 * time.Millisecond)
+	if n := len(server.Calls("sendMessage")); n != paused {
+		t.Fatalf("%d messages sent while paused", n-paused)
+	}
+	if err := s.Resume(b.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-inFlight:
+	case <-time.After(10 * time.Second):
+		t.Fatal("chat 50 not sent after resume")
+	}
+	if err := s.Cancel(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after Cancel")
+	}
+
+	// 发送中的 502 不会重新创建队列
+	key := redis.GetCacheKey(queueKey(b.ID))
+	if n, _ := redis.GetRedis().Exists(context.Background(), key).Result(); n != 0 {
+		t.Errorf("queue of a cancelled broadcast still exists")
+	}
+	if b, err = s.Get(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if b.Status != StatusCancelled || b.Delivered >= 100 {
+		t.Errorf("status = %s, delivered %d, want cancelled before the end", b.Status, b.Delivered)
+	}
+	if err := s.Run(ctx, b.ID); err != ErrFinished {
+		t.Errorf("Run() after cancel = %v, want ErrFinished", err)
+	}
+}
@@ -0,0 +1,143 @@
+package detectlang
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// BatchResult the detection result of one text of a batch
+type BatchResult struct {
+	Index  int // 在输入中的位置
+	Result *LanguageDetectionResult
+	Err    error
+}
+
+// workerCount workers <= 0 means one per CPU
+func workerCount(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// DetectBatch detect the texts with at most workers goroutines sharing the
+// detector, workers <= 0 uses one per CPU. Results are in the order of texts,
+// a text that fails has its own Err. When ctx is done the texts not yet
+// detected get ctx.Err() and it is also returned.
+func (c *Client) DetectBatch(ctx context.Context, texts []string, workers int) ([]BatchResult, error) {
+	results := make([]BatchResult, len(texts))
+	for i := range results {
+		results[i].Index = i
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workerCount(workers), len(texts)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Result, results[i].Err = c.Detect(texts[i])
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(texts); next++ {
+		// select 在两者都就绪时随机选择，取消后不再发送
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if next < len(texts) {
+		for i := next; i < len(texts); i++ {
+			results[i].Err = ctx.Err()
+		}
+		return results, ctx.Err()
+	}
+	return results, nil
+}
+
+// DetectStream detect the texts read from in with at most workers goroutines,
+// workers <= 0 uses one per CPU. Results are sent in the order of in, a text
+// that fails has its own Err. The channel is closed once in is closed and all
+// results are sent, or as soon as ctx is done.
+func (c *Client) DetectStream(ctx context.Context, in <-chan string, workers int) <-chan BatchResult {
+	workers = workerCount(workers)
+	out := make(chan BatchResult)
+	// pending 按输入顺序排队的结果，容量限制了同时处理的数量
+	pending := make(chan chan BatchResult, workers)
+	jobs := make(chan func())
+
+	for range workers {
+		go func() {
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		for index := 0; ; index++ {
+			if ctx.Err() != nil {
+				return
+			}
+			var text string
+			var ok bool
+			select {
+			case text, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			done := make(chan BatchResult, 1)
+			select {
+			case pending <- done:
+			case <-ctx.Done():
+				return
+			}
+			i := index
+			job := func() {
+				result, err := c.Detect(text)
+				done <- BatchResult{Index: i, Result: result, Err: err}
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				done <- BatchResult{Index: i, Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(out)
+		for done := range pending {
+			result := <-done
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				// 丢弃剩余结果，让工作协程退出
+				for range pending {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,64 @@
+package detectlang
+
+import (
+	"container/list"
+	"sync"
+)
+
+// maxCachedTextLen only short texts are cached, long ones rarely repeat
+const maxCachedTextLen = 256
+
+// resultCache a small LRU cache of detection results
+type resultCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // 最近使用的在前
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	results []LanguageDetectionResult
+}
+
+func newResultCache(size int) *resultCache {
+	return &resultCache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// get return a copy of the results cached for text, kind separates the APIs
+func (c *resultCache) get(kind, text string) ([]LanguageDetectionResult, bool) {
+	if c == nil || len(text) > maxCachedTextLen {
+		return nil, false
+	}
+	key := kind + "\x00" + text
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return append([]LanguageDetectionResult(nil), elem.Value.(*cacheEntry).results...), true
+}
+
+// put store the results, evicting the least recently used entry when full
+func (c *resultCache) put(kind, text string, results []LanguageDetectionResult) {
+	if c == nil || len(text) > maxCachedTextLen {
+		return
+	}
+	key := kind + "\x00" + text
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results = append([]LanguageDetectionResult(nil), results...)
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).results = results
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key, results})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,162 @@
+package detectlang
+
+import (
+	"fmt"
+	"sync"
+	"unicode"
+
+	"github.com/longbridgeapp/opencc"
+	"golang.org/x/text/language"
+)
+
+// Conversions between Chinese variants
+const (
+	S2T  = "s2t"  // 简体 => 繁体
+	T2S  = "t2s"  // 繁体 => 简体
+	S2TW = "s2tw" // 简体 => 台湾正体
+	S2HK = "s2hk" // 简体 => 香港繁体
+	TW2S = "tw2s" // 台湾正体 => 简体
+	HK2S = "hk2s" // 香港繁体 => 简体
+	T2TW = "t2tw" // 繁体 => 台湾正体
+	T2HK = "t2hk" // 繁体 => 香港繁体
+	TW2T = "tw2t" // 台湾正体 => 繁体
+	HK2T = "hk2t" // 香港繁体 => 繁体
+)
+
+var (
+	TaiwanChinese   = language.MustParse("zh-Hant-TW")
+	HongKongChinese = language.MustParse("zh-Hant-HK")
+)
+
+// maxClassifyRunes only the first Han characters are scored
+const maxClassifyRunes = 1000
+
+var converters sync.Map // conversion => *opencc.OpenCC
+
+// converter load the dictionaries of a conversion once
+func converter(conversion string) (*opencc.OpenCC, error) {
+	if cc, ok := converters.Load(conversion); ok {
+		return cc.(*opencc.OpenCC), nil
+	}
+	switch conversion {
+	case S2T, T2S, S2TW, S2HK, TW2S, HK2S, T2TW, T2HK, TW2T, HK2T:
+	default:
+		return nil, fmt.Errorf("unsupported conversion: %s", conversion)
+	}
+	cc, err := opencc.New(conversion)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := converters.LoadOrStore(conversion, cc)
+	return actual.(*opencc.OpenCC), nil
+}
+
+// Convert convert text between Chinese variants, conversion is one of the
+// constants above, e.g. S2T or TW2S
+func Convert(text, conversion string) (string, error) {
+	cc, err := converter(conversion)
+	if err != nil {
+		return "", err
+	}
+	return cc.Convert(text)
+}
+
+// hanFlags which conversions change a Han character
+type hanFlags struct {
+	simplified  bool // s2t 会改变，只在简体中使用
+	traditional bool // t2s 会改变，只在繁体中使用
+	tw          bool // tw2t 会改变，台湾用字，如 裡、著
+	hk          bool // hk2t 会改变，香港用字
+	notTW       bool // t2tw 会改变，不是台湾用字，如 裏、着
+	notHK       bool // t2hk 会改变，不是香港用字
+}
+
+// cantonese characters of written Cantonese, only used in Hong Kong
+var cantonese = map[rune]bool{
+	'喺': true, '嘅': true, '咗': true, '嘢': true, '冇': true, '佢': true,
+	'哋': true, '啲': true, '嗰': true, '唔': true, '睇': true, '咁': true,
+}
+
+// taiwanese standard characters of Taiwan which tw2t leaves unchanged
+var taiwanese = map[rune]bool{
+	'裡': true, '啟': true, '汙': true,
+}
+
+var hanCache sync.Map // rune => hanFlags
+
+// classifyHan convert the character on its own, results are cached
+func classifyHan(r rune) hanFlags {
+	if flags, ok := hanCache.Load(r); ok {
+		return flags.(hanFlags)
+	}
+	changed := func(conversion string) bool {
+		out, err := Convert(string(r), conversion)
+		return err == nil && out != string(r)
+	}
+	flags := hanFlags{
+		simplified:  changed(S2T),
+		traditional: changed(T2S),
+		tw:          changed(TW2T) || taiwanese[r],
+		hk:          changed(HK2T) || cantonese[r],
+		notTW:       changed(T2TW),
+		notHK:       changed(T2HK),
+	}
+	hanCache.Store(r, flags)
+	return flags
+}
+
+// ClassifyChinese tell simplified from traditional Chinese by scoring each
+// Han character against the s2t and t2s conversions, traditional text is
+// further told apart as Taiwan or Hong Kong when their variants differ.
+//
+// Returns zh-Hans, zh-Hant, zh-Hant-TW or zh-Hant-HK with a confidence
+// between 0.5 and 1; text without distinguishing characters is zh-Hans
+// with confidence 0.5.
+func ClassifyChinese(text string) (language.Tag, float64) {
+	var simplified, traditional, tw, hk, count int
+	for _, r := range text {
+		if !unicode.Is(unicode.Han, r) {
+			continue
+		}
+		if count++; count > maxClassifyRunes {
+			break
+		}
+		flags := classifyHan(r)
+		if flags.simplified {
+			simplified++
+		}
+		if flags.traditional {
+			traditional++
+		}
+		if flags.tw || flags.notHK {
+			tw++
+		}
+		if flags.hk || flags.notTW {
+			hk++
+		}
+	}
+
+	if simplified+traditional == 0 {
+		return language.SimplifiedChinese, 0.5
+	}
+	if simplified >= traditional {
+		return language.SimplifiedChinese, float64(simplified) / float64(simplified+traditional)
+	}
+	confidence := float64(traditional) / float64(simplified+traditional)
+	switch {
+	case tw > hk:
+		return TaiwanChinese, confidence
+	case hk > tw:
+		return HongKongChinese, confidence
+	}
+	return language.TraditionalChinese, confidence
+}
+
+// detectChineseVariant simplified or traditional, without the region
+func detectChineseVariant(text string) language.Tag {
+	tag, _ := ClassifyChinese(text)
+	if tag == language.SimplifiedChinese {
+		return tag
+	}
+	return language.TraditionalChinese
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/labstack/gommon/log"
-	"github.com/longbridgeapp/opencc"
 	"github.com/pemistahl/lingua-go"
 	"golang.org/x/text/language"
 )
@@ -15,20 +14,7 @@ type LanguageDetectionResult struct {
 	Confidence float64
 }
 
-var s2tConverter *opencc.OpenCC
-var t2sConverter *opencc.OpenCC
-
 func init() {
-	var err error
-	s2tConverter, err = opencc.New("s2t")
-	if err != nil {
-		panic(err)
-	}
-	t2sConverter, err = opencc.New("t2s")
-	if err != nil {
-		panic(err)
-	}
-
 	for tag, v := range tagToLingua {
 		// For Chinese, we choose to use SimplifiedChinese as the default mapping
 		if v == lingua.Chinese && linguaToTag[v] == language.Und {
@@ -42,10 +28,48 @@ func init() {
 type Client struct {
 	detector lingua.LanguageDetector
 	langs    []lingua.Language
+
+	minDistance float64
+	lowAccuracy bool
+	preload     bool
+	cacheSize   int
+	cache       *resultCache
+}
+
+// Option configure a Client
+type Option func(*Client)
+
+// WithMinimumRelativeDistance set how far apart the top two languages must be
+// for Detect to answer, between 0 and 0.99, default 0
+func WithMinimumRelativeDistance(distance float64) Option {
+	return func(c *Client) {
+		c.minDistance = distance
+	}
+}
+
+// WithLowAccuracyMode only use trigrams, faster and smaller but less accurate on short texts
+func WithLowAccuracyMode() Option {
+	return func(c *Client) {
+		c.lowAccuracy = true
+	}
+}
+
+// WithPreloadedModels load all language models at New instead of on first use
+func WithPreloadedModels() Option {
+	return func(c *Client) {
+		c.preload = true
+	}
+}
+
+// WithCacheSize set how many results of short texts are cached, default 1000, 0 disables the cache
+func WithCacheSize(size int) Option {
+	return func(c *Client) {
+		c.cacheSize = size
+	}
 }
 
 // New initialization function
-func New(languages []language.Tag) *Client {
+func New(languages []language.Tag, options ...Option) *Client {
 	var linguaLanguages []lingua.Language
 	for _, tag := range languages {
 		if linguaLang, ok := tagToLingua[tag]; ok {
@@ -55,41 +79,28 @@ func New(languages []language.Tag) *Client {
 		}
 	}
 
-	detector := lingua.NewLanguageDetectorBuilder().
-		FromLanguages(linguaLanguages...).
-		Build()
-
-	return &Client{
-		detector: detector,
-		langs:    linguaLanguages,
+	c := &Client{
+		langs:     linguaLanguages,
+		cacheSize: 1000,
 	}
-}
-
-// Detect Chinese variant (Simplified or Traditional)
-func detectChineseVariant(text string) language.Tag {
-	// 只处理前 1000 个字符
-	maxLen := 1000
-	if len(text) > maxLen {
-		text = text[:maxLen]
+	for _, option := range options {
+		option(c)
 	}
 
-	s2tText, err := s2tConverter.Convert(text)
-	if err != nil {
-		return language.SimplifiedChinese
+	builder := lingua.NewLanguageDetectorBuilder().
+		FromLanguages(linguaLanguages...).
+		WithMinimumRelativeDistance(c.minDistance)
+	if c.lowAccuracy {
+		builder = builder.WithLowAccuracyMode()
 	}
-
-	diffCount := 0
-	for i := 0; i < len(text); i++ {
-		if text[i] != s2tText[i] {
-			diffCount++
-		}
+	if c.preload {
+		builder = builder.WithPreloadedLanguageModels()
 	}
-
-	// 如果差异字符数量超过 10%，认为是简体中文
-	if float64(diffCount)/float64(len(text)) > 0.1 {
-		return language.SimplifiedChinese
+	c.detector = builder.Build()
+	if c.cacheSize > 0 {
+		c.cache = newResultCache(c.cacheSize)
 	}
-	return language.TraditionalChinese
+	return c
 }
 
 // Calculate Levenshtein distance
@@ -131,6 +142,12 @@ func (c *Client) Detect(text string) (*LanguageDetectionResult, error) {
 	if c.detector == nil {
 		return nil, fmt.Errorf("language detector is not initialized, please call InitializeDetector first")
 	}
+	if tag, ok := c.detectScript(text); ok {
+		return &LanguageDetectionResult{Language: tag, Confidence: 1}, nil
+	}
+	if cached, ok := c.cache.get("detect", text); ok {
+		return &cached[0], nil
+	}
 
 	detectedLang, exists := c.detector.DetectLanguageOf(text)
 	if !exists {
@@ -150,8 +167,43 @@ func (c *Client) Detect(text string) (*LanguageDetectionResult, error) {
 		resultTag = detectChineseVariant(text)
 	}
 
-	return &LanguageDetectionResult{
+	result := LanguageDetectionResult{
 		Language:   resultTag,
 		Confidence: confidence,
-	}, nil
+	}
+	c.cache.put("detect", text, []LanguageDetectionResult{result})
+	return &result, nil
+}
+
+// DetectTopN return the n most likely languages with their confidences, sorted
+// by confidence, n <= 0 returns all candidates
+func (c *Client) DetectTopN(text string, n int) ([]LanguageDetectionResult, error) {
+	if text == "" {
+		return nil, fmt.Errorf("input text is empty")
+	}
+	if c.detector == nil {
+		return nil, fmt.Errorf("language detector is not initialized, please call InitializeDetector first")
+	}
+	if tag, ok := c.detectScript(text); ok {
+		return []LanguageDetectionResult{{Language: tag, Confidence: 1}}, nil
+	}
+
+	results, ok := c.cache.get("top", text)
+	if !ok {
+		for _, value := range c.detector.ComputeLanguageConfidenceValues(text) {
+			tag, ok := linguaToTag[value.Language()]
+			if !ok {
+				continue
+			}
+			if value.Language() == lingua.Chinese {
+				tag = detectChineseVariant(text)
+			}
+			results = append(results, LanguageDetectionResult{Language: tag, Confidence: value.Value()})
+		}
+		c.cache.put("top", text, results)
+	}
+	if n > 0 && len(results) > n {
+		results = results[:n]
+	}
+	return results, nil
 }
@@ -1,6 +1,8 @@
 package detectlang
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -66,3 +68,218 @@ func TestDetectLanguage(t *testing.T) {
 		fmt.Println("---")
 	}
 }
+
+func TestDetectSegments(t *testing.T) {
+	client := New([]language.Tag{language.English, language.SimplifiedChinese, language.TraditionalChinese, language.Russian, language.French, language.German})
+
+	text := "Привет, как у тебя дела сегодня? I am fine, thank you very much for asking. 我今天很高兴见到你们大家"
+	segments, err := client.DetectSegments(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []language.Tag{language.Russian, language.English, language.SimplifiedChinese}
+	if len(segments) != len(want) {
+		t.Fatalf("DetectSegments() = %+v", segments)
+	}
+	end := 0
+	for i, segment := range segments {
+		if segment.Language != want[i] || segment.Start != end || text[segment.Start:segment.End] != segment.Text || segment.Confidence <= 0 {
+			t.Errorf("segment %d = %+v, want %s", i, segment, want[i])
+		}
+		end = segment.End
+	}
+	if end != len(text) {
+		t.Errorf("segments end at %d, want %d", end, len(text))
+	}
+
+	segments, err = client.DetectSegments("這是一個繁體中文的例子，我們今天見面。")
+	if err != nil || len(segments) != 1 || segments[0].Language != language.TraditionalChinese {
+		t.Errorf("DetectSegments(traditional) = %+v, %v", segments, err)
+	}
+	if _, err := client.DetectSegments(""); err == nil {
+		t.Error("DetectSegments(\"\") = nil")
+	}
+}
+
+func TestDetectTopN(t *testing.T) {
+	client := New([]language.Tag{language.English, language.French, language.German, language.Korean, language.Greek, language.Japanese, language.SimplifiedChinese},
+		WithMinimumRelativeDistance(0.1), WithLowAccuracyMode(), WithCacheSize(2))
+
+	results, err := client.DetectTopN("This is an English example of a longer sentence.", 2)
+	if err != nil || len(results) != 2 || results[0].Language != language.English || results[0].Confidence < results[1].Confidence {
+		t.Errorf("DetectTopN() = %+v, %v", results, err)
+	}
+	if all, _ := client.DetectTopN("This is an English example of a longer sentence.", 0); len(all) < 2 {
+		t.Errorf("DetectTopN(0) = %+v", all)
+	}
+
+	for text, want := range map[string]language.Tag{
+		"안녕하세요, 반갑습니다!": language.Korean,
+		"Καλημέρα σας":  language.Greek,
+		"こんにちは、世界":      language.Japanese,
+	} {
+		if result, err := client.Detect(text); err != nil || result.Language != want || result.Confidence != 1 {
+			t.Errorf("Detect(%q) = %+v, %v", text, result, err)
+		}
+	}
+	// 未配置的语言不走快速路径
+	if tag, ok := New([]language.Tag{language.English, language.French}).detectScript("สวัสดีครับ"); ok {
+		t.Errorf("detectScript() = %s without Thai", tag)
+	}
+	if _, ok := client.detectScript("Hello 안녕"); ok {
+		t.Error("detectScript() with mixed scripts")
+	}
+
+	cache := newResultCache(2)
+	cache.put("detect", "a", []LanguageDetectionResult{{Language: language.English}})
+	cache.put("detect", "b", []LanguageDetectionResult{{Language: language.French}})
+	cache.get("detect", "a")
+	cache.put("detect", "c", []LanguageDetectionResult{{Language: language.German}})
+	if _, ok := cache.get("detect", "b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if results, ok := cache.get("detect", "a"); !ok || results[0].Language != language.English {
+		t.Errorf("cache.get(a) = %+v, %v", results, ok)
+	}
+}
+
+func TestClassifyChinese(t *testing.T) {
+	for text, want := range map[string]language.Tag{
+		"这是一个简体中文的例子。": language.SimplifiedChinese,
+		"這是一個繁體中文的例子。": language.TraditionalChinese,
+		"佢哋喺度食緊飯，唔該晒。": HongKongChinese,
+		"臺灣的軟體很棒":      TaiwanChinese,
+		"在這裡著作，線上遊戲":   TaiwanChinese,
+	} {
+		if tag, confidence := ClassifyChinese(text); tag != want || confidence < 0.5 || confidence > 1 {
+			t.Errorf("ClassifyChinese(%q) = %s, %v, want %s", text, tag, confidence, want)
+		}
+	}
+	if tag, confidence := ClassifyChinese("Hello"); tag != language.SimplifiedChinese || confidence != 0.5 {
+		t.Errorf("ClassifyChinese(Hello) = %s, %v", tag, confidence)
+	}
+
+	for conversion, want := range map[string]string{
+		S2T:  "軟件裏的頭髮着涼了",
+		S2TW: "軟件裡的頭髮著涼了",
+	} {
+		if got, err := Convert("软件里的头发着凉了", conversion); err != nil || got != want {
+			t.Errorf("Convert(%s) = %q, %v, want %q", conversion, got, err, want)
+		}
+	}
+	if got, err := Convert("軟件裏的頭髮", T2S); err != nil || got != "软件里的头发" {
+		t.Errorf("Convert(t2s) = %q, %v", got, err)
+	}
+	if _, err := Convert("软件", "s2x"); err == nil {
+		t.Error("Convert() with unknown conversion should fail")
+	}
+}
+
+var batchTexts = []string{
+	"Hello, how are you doing today?",
+	"Bonjour, comment allez-vous aujourd'hui ?",
+	"",
+	"这是一个简体中文的例子。",
+	"Guten Tag, wie geht es Ihnen heute?",
+}
+
+func TestDetectBatch(t *testing.T) {
+	client := New([]language.Tag{language.English, language.French, language.German, language.SimplifiedChinese})
+	want := []language.Tag{language.English, language.French, language.Und, language.SimplifiedChinese, language.German}
+
+	results, err := client.DetectBatch(context.Background(), batchTexts, 2)
+	if err != nil {
+		t.Fatalf("DetectBatch() error = %v", err)
+	}
+	for i, result := range results {
+		if result.Index != i {
+			t.Errorf("results[%d].Index = %d", i, result.Index)
+		}
+		if want[i] == language.Und {
+			if result.Err == nil {
+				t.Errorf("results[%d] of empty text should fail", i)
+			}
+			continue
+		}
+		if result.Err != nil || result.Result.Language != want[i] {
+			t.Errorf("results[%d] = %+v, %v, want %s", i, result.Result, result.Err, want[i])
+		}
+	}
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, text := range batchTexts {
+			in <- text
+		}
+	}()
+	i := 0
+	for result := range client.DetectStream(context.Background(), in, 3) {
+		if result.Index != i || (want[i] != language.Und && (result.Err != nil || result.Result.Language != want[i])) {
+			t.Errorf("stream result %d = %+v", i, result)
+		}
+		i++
+	}
+	if i != len(batchTexts) {
+		t.Errorf("DetectStream() sent %d results, want %d", i, len(batchTexts))
+	}
+
+	// 已取消的上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = client.DetectBatch(ctx, batchTexts, 2)
+	if !errors.Is(err, context.Canceled) || len(results) != len(batchTexts) {
+		t.Fatalf("DetectBatch() = %d results, %v", len(results), err)
+	}
+	for _, result := range results {
+		if !errors.Is(result.Err, context.Canceled) || result.Result != nil {
+			t.Errorf("result %d after cancel = %+v", result.Index, result)
+		}
+	}
+	in = make(chan string)
+	for result := range client.DetectStream(ctx, in, 2) {
+		t.Errorf("DetectStream() sent %+v after cancel", result)
+	}
+}
+
+func benchmarkTexts(n int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		// 避开缓存
+		texts[i] = fmt.Sprintf("%s %d", batchTexts[i%2], i)
+	}
+	return texts
+}
+
+func BenchmarkDetect(b *testing.B) {
+	client := New([]language.Tag{language.English, language.French, language.German}, WithCacheSize(0), WithPreloadedModels())
+	texts := benchmarkTexts(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.Detect(texts[i%len(texts)])
+	}
+}
+
+func BenchmarkDetectBatch(b *testing.B) {
+	client := New([]language.Tag{language.English, language.French, language.German}, WithCacheSize(0), WithPreloadedModels())
+	texts := benchmarkTexts(b.N)
+	b.ResetTimer()
+	if _, err := client.DetectBatch(context.Background(), texts, 0); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkDetectStream(b *testing.B) {
+	client := New([]language.Tag{language.English, language.French, language.German}, WithCacheSize(0), WithPreloadedModels())
+	texts := benchmarkTexts(b.N)
+	in := make(chan string)
+	b.ResetTimer()
+	go func() {
+		defer close(in)
+		for _, text := range texts {
+			in <- text
+		}
+	}()
+	for range client.DetectStream(context.Background(), in, 0) {
+	}
+}
@@ -0,0 +1,86 @@
+package detectlang
+
+import (
+	"unicode"
+
+	"golang.org/x/text/language"
+)
+
+// scriptLanguages scripts written by a single supported language
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	tag    language.Tag
+}{
+	{unicode.Hangul, language.Korean},
+	{unicode.Thai, language.Thai},
+	{unicode.Greek, language.Greek},
+	{unicode.Hebrew, language.Hebrew},
+	{unicode.Armenian, language.Armenian},
+	{unicode.Bengali, language.Bengali},
+	{unicode.Gujarati, language.Gujarati},
+	{unicode.Gurmukhi, language.Punjabi},
+	{unicode.Tamil, language.Tamil},
+	{unicode.Telugu, language.Telugu},
+}
+
+// detectScript answer a text whose letters are all in the script of a
+// single configured language, without running the n-gram models.
+// Kana with or without Han is Japanese.
+func (c *Client) detectScript(text string) (language.Tag, bool) {
+	found := -1
+	kana, han := false, false
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana = true
+			continue
+		case unicode.Is(unicode.Han, r):
+			han = true
+			continue
+		}
+		if found >= 0 && unicode.Is(scriptLanguages[found].script, r) {
+			continue
+		}
+		if found >= 0 {
+			return language.Und, false
+		}
+		for i, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				found = i
+				break
+			}
+		}
+		// 拉丁、西里尔等多种语言共用的文字
+		if found < 0 {
+			return language.Und, false
+		}
+	}
+
+	var tag language.Tag
+	switch {
+	case kana && found < 0:
+		tag = language.Japanese
+	case found >= 0 && !kana && !han:
+		tag = scriptLanguages[found].tag
+	default:
+		return language.Und, false
+	}
+	return tag, c.supports(tag)
+}
+
+// supports check whether the language is one of the client's
+func (c *Client) supports(tag language.Tag) bool {
+	want, ok := tagToLingua[tag]
+	if !ok {
+		return false
+	}
+	for _, lang := range c.langs {
+		if lang == want {
+			return true
+		}
+	}
+	return false
+}
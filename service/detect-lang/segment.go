@@ -0,0 +1,51 @@
+package detectlang
+
+import (
+	"fmt"
+
+	"github.com/pemistahl/lingua-go"
+	"golang.org/x/text/language"
+)
+
+// Segment is a single-language span of a text
+type Segment struct {
+	Start      int // 字节偏移，text[Start:End]
+	End        int
+	Text       string
+	Language   language.Tag
+	Confidence float64
+}
+
+// DetectSegments split a mixed-language text into single-language spans,
+// the Chinese variant is resolved per span. Spans of unknown language have
+// language.Und and zero confidence.
+func (c *Client) DetectSegments(text string) ([]Segment, error) {
+	if text == "" {
+		return nil, fmt.Errorf("input text is empty")
+	}
+	if c.detector == nil {
+		return nil, fmt.Errorf("language detector is not initialized, please call InitializeDetector first")
+	}
+
+	results := c.detector.DetectMultipleLanguagesOf(text)
+	segments := make([]Segment, 0, len(results))
+	for _, result := range results {
+		segment := Segment{
+			Start:    result.StartIndex(),
+			End:      result.EndIndex(),
+			Text:     text[result.StartIndex():result.EndIndex()],
+			Language: language.Und,
+		}
+		if result.Language() != lingua.Unknown {
+			if tag, ok := linguaToTag[result.Language()]; ok {
+				segment.Language = tag
+				segment.Confidence = c.detector.ComputeLanguageConfidence(segment.Text, result.Language())
+			}
+			if result.Language() == lingua.Chinese {
+				segment.Language = detectChineseVariant(segment.Text)
+			}
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
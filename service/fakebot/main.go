@@ -0,0 +1,401 @@
+package fakebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mylukin/EchoPilot/helper"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Call is a recorded Bot API request
+type Call struct {
+	Token  string
+	Method string
+	Params map[string]string
+	Time   time.Time
+}
+
+// Int64 get a param as int64
+func (call Call) Int64(key string) int64 {
+	n, _ := strconv.ParseInt(call.Params[key], 10, 64)
+	return n
+}
+
+// HandlerFunc answers a Bot API method, the result is encoded as "result"
+type HandlerFunc func(call Call) (any, error)
+
+// Error is a Bot API error response
+type Error struct {
+	Code        int
+	Description string
+	// RetryAfter is sent as parameters.retry_after, e.g. with code 429
+	RetryAfter int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Description)
+}
+
+// Server is an in-process fake Telegram Bot API
+//
+//	server := fakebot.New()
+//	defer server.Close()
+//	bot, _ := tele.NewBot(tele.Settings{URL: server.URL, Token: token, Poller: &tele.LongPoller{}})
+type Server struct {
+	*httptest.Server
+	Echo *echo.Echo
+
+	mu            sync.Mutex
+	calls         []Call
+	handlers      map[string]HandlerFunc
+	updates       []tele.Update
+	nextUpdateID  int
+	nextMessageID int
+	notify        chan struct{}
+	webhook       tele.Webhook
+}
+
+// New start a fake Bot API server
+func New() *Server {
+	s := &Server{
+		Echo:          echo.New(),
+		handlers:      map[string]HandlerFunc{},
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		notify:        make(chan struct{}),
+	}
+	s.Echo.HideBanner = true
+	s.Echo.HidePort = true
+	s.Echo.POST("/bot:token/:method", s.serve)
+	s.Echo.GET("/bot:token/:method", s.serve)
+
+	s.Handle("getMe", s.getMe)
+	s.Handle("sendMessage", s.sendMessage)
+	s.Handle("editMessageText", s.editMessageText)
+	s.Handle("answerCallbackQuery", func(call Call) (any, error) { return true, nil })
+	s.Handle("setWebhook", s.setWebhook)
+	s.Handle("deleteWebhook", s.deleteWebhook)
+	s.Handle("getWebhookInfo", s.getWebhookInfo)
+
+	s.Server = httptest.NewServer(s.Echo)
+	return s
+}
+
+// Handle set the handler of a method, override the built-in ones or add more
+func (s *Server) Handle(method string, handler HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = handler
+}
+
+// Calls get the recorded calls, all calls if no method given
+func (s *Server) Calls(methods ...string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := []Call{}
+	for _, call := range s.calls {
+		if len(methods) == 0 || helper.ValueInSlice(call.Method, methods) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// LastCall get the last call of method
+func (s *Server) LastCall(method string) (Call, bool) {
+	calls := s.Calls(method)
+	if len(calls) == 0 {
+		return Call{}, false
+	}
+	return calls[len(calls)-1], true
+}
+
+// WaitCall wait until method is called n times in total
+func (s *Server) WaitCall(method string, n int, timeout time.Duration) ([]Call, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		calls := s.Calls(method)
+		if len(calls) >= n {
+			return calls, true
+		}
+		if time.Now().After(deadline) {
+			return calls, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Reset drop recorded calls and pending updates
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	s.updates = nil
+}
+
+// Inject an update, it is delivered to the webhook if set, otherwise returned by getUpdates
+func (s *Server) Inject(update tele.Update) error {
+	s.mu.Lock()
+	update.ID = s.nextUpdateID
+	s.nextUpdateID++
+	webhook := s.webhook
+	if webhook.Listen == "" {
+		s.updates = append(s.updates, update)
+		close(s.notify)
+		s.notify = make(chan struct{})
+	}
+	s.mu.Unlock()
+
+	if webhook.Listen == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhook.Listen, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if webhook.SecretToken != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", webhook.SecretToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fakebot: webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// SendText inject a private text message from user
+func (s *Server) SendText(userID int64, text string) error {
+	return s.Inject(tele.Update{Message: s.newMessage(userID, userID, text)})
+}
+
+// SendCallback inject a callback query pressing a button with data
+func (s *Server) SendCallback(userID int64, data string) error {
+	return s.Inject(tele.Update{Callback: &tele.Callback{
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		Sender:  &tele.User{ID: userID, FirstName: "User"},
+		Message: s.newMessage(userID, userID, ""),
+		Data:    data,
+	}})
+}
+
+// serve record the call and answer it
+func (s *Server) serve(c echo.Context) error {
+	token := c.Param("token")
+	if !helper.IsBotToken(token) {
+		return c.JSON(http.StatusUnauthorized, map[string]any{"ok": false, "error_code": 401, "description": "Unauthorized"})
+	}
+
+	params, err := readParams(c.Request())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"ok": false, "error_code": 400, "description": "Bad Request: " + err.Error()})
+	}
+	call := Call{Token: token, Method: c.Param("method"), Params: params, Time: time.Now()}
+
+	// getUpdates 不记录，避免长轮询刷满记录
+	if call.Method == "getUpdates" {
+		return s.getUpdates(c, call)
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	handler, ok := s.handlers[call.Method]
+	s.mu.Unlock()
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]any{"ok": false, "error_code": 404, "description": "Not Found: method not found"})
+	}
+
+	result, err := handler(call)
+	if err != nil {
+		resp := map[string]any{"ok": false, "error_code": http.StatusBadRequest, "description": err.Error()}
+		if e, ok := err.(*Error); ok {
+			resp["error_code"] = e.Code
+			resp["description"] = e.Description
+			if e.RetryAfter > 0 {
+				resp["parameters"] = map[string]any{"retry_after": e.RetryAfter}
+			}
+		}
+		return c.JSON(resp["error_code"].(int), resp)
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true, "result": result})
+}
+
+// getUpdates long polling, confirm updates before offset
+func (s *Server) getUpdates(c echo.Context, call Call) error {
+	offset := int(call.Int64("offset"))
+	timeout := time.Duration(call.Int64("timeout")) * time.Second
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		for len(s.updates) > 0 && s.updates[0].ID < offset {
+			s.updates = s.updates[1:]
+		}
+		updates := append([]tele.Update{}, s.updates...)
+		notify := s.notify
+		s.mu.Unlock()
+
+		if len(updates) > 0 || timeout <= 0 {
+			return c.JSON(http.StatusOK, map[string]any{"ok": true, "result": updates})
+		}
+		select {
+		case <-notify:
+		case <-deadline:
+			timeout = 0
+		case <-c.Request().Context().Done():
+			return nil
+		}
+	}
+}
+
+// getMe the bot user, ID is taken from the token
+func (s *Server) getMe(call Call) (any, error) {
+	return tele.User{
+		ID:        helper.GetBotID(call.Token),
+		IsBot:     true,
+		FirstName: "Fake Bot",
+		Username:  fmt.Sprintf("fake%d_bot", helper.GetBotID(call.Token)),
+	}, nil
+}
+
+// sendMessage echo the message back with a new ID
+func (s *Server) sendMessage(call Call) (any, error) {
+	chatID := call.Int64("chat_id")
+	if chatID == 0 {
+		return nil, &Error{Code: 400, Description: "Bad Request: chat not found"}
+	}
+	if call.Params["text"] == "" {
+		return nil, &Error{Code: 400, Description: "Bad Request: message text is empty"}
+	}
+	message := s.newMessage(chatID, helper.GetBotID(call.Token), call.Params["text"])
+	message.Sender.IsBot = true
+	return message, nil
+}
+
+// editMessageText echo the edited message, true for inline messages
+func (s *Server) editMessageText(call Call) (any, error) {
+	if call.Params["inline_message_id"] != "" {
+		return true, nil
+	}
+	chatID := call.Int64("chat_id")
+	messageID := call.Int64("message_id")
+	if chatID == 0 || messageID == 0 {
+		return nil, &Error{Code: 400, Description: "Bad Request: message to edit not found"}
+	}
+	return &tele.Message{
+		ID:       int(messageID),
+		Sender:   &tele.User{ID: helper.GetBotID(call.Token), IsBot: true},
+		Chat:     &tele.Chat{ID: chatID, Type: chatType(chatID)},
+		Text:     call.Params["text"],
+		Unixtime: time.Now().Unix(),
+		LastEdit: time.Now().Unix(),
+	}, nil
+}
+
+// setWebhook remember the url, injected updates are posted to it
+func (s *Server) setWebhook(call Call) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhook = tele.Webhook{Listen: call.Params["url"], SecretToken: call.Params["secret_token"]}
+	return true, nil
+}
+
+// deleteWebhook go back to getUpdates
+func (s *Server) deleteWebhook(call Call) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhook = tele.Webhook{}
+	return true, nil
+}
+
+// getWebhookInfo the current webhook
+func (s *Server) getWebhookInfo(call Call) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{"url": s.webhook.Listen, "pending_update_count": len(s.updates)}, nil
+}
+
+// newMessage build a text message in chat
+func (s *Server) newMessage(chatID, userID int64, text string) *tele.Message {
+	s.mu.Lock()
+	id := s.nextMessageID
+	s.nextMessageID++
+	s.mu.Unlock()
+	return &tele.Message{
+		ID:       id,
+		Sender:   &tele.User{ID: userID, FirstName: "User"},
+		Chat:     &tele.Chat{ID: chatID, Type: chatType(chatID)},
+		Text:     text,
+		Unixtime: time.Now().Unix(),
+	}
+}
+
+// chatType negative IDs are groups
+func chatType(chatID int64) tele.ChatType {
+	if chatID < 0 {
+		return tele.ChatSuperGroup
+	}
+	return tele.ChatPrivate
+}
+
+// readParams read JSON, form or multipart params as strings
+func readParams(req *http.Request) (map[string]string, error) {
+	params := map[string]string{}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json":
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(body)) == 0 || string(bytes.TrimSpace(body)) == "null" {
+			return params, nil
+		}
+		raw := map[string]json.RawMessage{}
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, err
+		}
+		for key, value := range raw {
+			var text string
+			if err := json.Unmarshal(value, &text); err == nil {
+				params[key] = text
+			} else {
+				params[key] = string(value)
+			}
+		}
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if err := req.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		for key, values := range req.MultipartForm.Value {
+			params[key] = values[0]
+		}
+	default:
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		for key := range req.Form {
+			params[key] = req.Form.Get(key)
+		}
+	}
+	return params, nil
+}
@@ -0,0 +1,135 @@
+package fakebot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+const testToken = "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1"
+
+func TestPolling(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	bot, err := tele.NewBot(tele.Settings{
+		URL:    server.URL,
+		Token:  testToken,
+		Poller: &tele.LongPoller{Timeout: time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bot.Me.ID != 123456789 {
+		t.Errorf("getMe ID = %d", bot.Me.ID)
+	}
+
+	bot.Handle(tele.OnText, func(c tele.Context) error {
+		msg, err := c.Bot().Send(c.Chat(), "echo: "+c.Text())
+		if err != nil {
+			return err
+		}
+		_, err = c.Bot().Edit(msg, "edited")
+		return err
+	})
+	bot.Handle(tele.OnCallback, func(c tele.Context) error {
+		return c.Respond(&tele.CallbackResponse{Text: c.Callback().Data})
+	})
+	go bot.Start()
+	defer bot.Stop()
+
+	if err := server.SendText(42, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.WaitCall("editMessageText", 1, 3*time.Second); !ok {
+		t.Fatalf("editMessageText not called, calls: %+v", server.Calls())
+	}
+	call, _ := server.LastCall("sendMessage")
+	if call.Int64("chat_id") != 42 || call.Params["text"] != "echo: hello" {
+		t.Errorf("sendMessage params = %v", call.Params)
+	}
+	call, _ = server.LastCall("editMessageText")
+	if call.Params["text"] != "edited" || call.Int64("message_id") == 0 {
+		t.Errorf("editMessageText params = %v", call.Params)
+	}
+
+	if err := server.SendCallback(42, "vote|1"); err != nil {
+		t.Fatal(err)
+	}
+	calls, ok := server.WaitCall("answerCallbackQuery", 1, 3*time.Second)
+	if !ok || calls[0].Params["text"] != "vote|1" {
+		t.Errorf("answerCallbackQuery calls = %+v", calls)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	received := make(chan tele.Update, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var update tele.Update
+		json.NewDecoder(r.Body).Decode(&update)
+		received <- update
+	}))
+	defer receiver.Close()
+
+	bot, err := tele.NewBot(tele.Settings{URL: server.URL, Token: testToken, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.SetWebhook(&tele.Webhook{SecretToken: "s3cret", Endpoint: &tele.WebhookEndpoint{PublicURL: receiver.URL}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := server.SendText(42, "/start"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case update := <-received:
+		if update.Message == nil || update.Message.Text != "/start" || update.ID == 0 {
+			t.Errorf("webhook got %+v", update)
+		}
+	default:
+		t.Errorf("webhook was not called")
+	}
+
+	if err := bot.RemoveWebhook(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.Calls("setWebhook", "deleteWebhook")); got != 2 {
+		t.Errorf("recorded %d webhook calls, want 2", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := New()
+	defer server.Close()
+
+	if _, err := tele.NewBot(tele.Settings{URL: server.URL, Token: "bad"}); err == nil {
+		t.Errorf("NewBot() should fail for a bad token")
+	}
+
+	bot, err := tele.NewBot(tele.Settings{URL: server.URL, Token: testToken, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.Raw("sendDice", nil); err == nil {
+		t.Errorf("unknown method should fail")
+	}
+
+	server.Handle("sendDice", func(call Call) (any, error) {
+		return tele.Message{ID: 1, Dice: &tele.Dice{Type: "🎲", Value: 6}}, nil
+	})
+	if _, err := bot.Raw("sendDice", map[string]string{"chat_id": "42"}); err != nil {
+		t.Errorf("sendDice error = %v", err)
+	}
+}
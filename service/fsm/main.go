@@ -0,0 +1,389 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Scope decides who shares a state
+type Scope int
+
+const (
+	// ScopeChatUser each user has own state in each chat
+	ScopeChatUser Scope = iota
+	// ScopeChat all users of a chat share one state
+	ScopeChat
+	// ScopeUser a user has one state across all chats
+	ScopeUser
+)
+
+// ErrNotRegistered is returned when the NextFn of a stored state is unknown to this process
+var ErrNotRegistered = errors.New("fsm: NextFn is not registered")
+
+// FSMValue is the state of a conversation
+type FSMValue struct {
+	// NextFn handles the next incoming message
+	NextFn tele.HandlerFunc `json:"-"`
+	// Payload is carried to the next step, read it with GetPayload
+	Payload any `json:"-"`
+	// Timeout of this step, zero means the machine TTL
+	Timeout time.Duration `json:"-"`
+	// ExpiredAt is when this step times out
+	ExpiredAt time.Time `json:"-"`
+}
+
+// fsmState is the stored form of FSMValue
+type fsmState struct {
+	Next      string          `json:"next"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	ExpiredAt int64           `json:"expired_at"`
+}
+
+// Machine is the FSM runtime
+type Machine struct {
+	handlers       map[string]tele.HandlerFunc
+	mu             sync.RWMutex
+	prefix         string
+	scope          Scope
+	ttl            time.Duration
+	cancelCommands []string
+	onCancel       tele.HandlerFunc
+	onTimeout      tele.HandlerFunc
+}
+
+type Option func(*Machine)
+
+// WithPrefix set the redis key prefix, default "fsm"
+func WithPrefix(prefix string) Option {
+	return func(m *Machine) {
+		m.prefix = prefix
+	}
+}
+
+// WithScope set who shares a state, default ScopeChatUser
+func WithScope(scope Scope) Option {
+	return func(m *Machine) {
+		m.scope = scope
+	}
+}
+
+// WithTTL set the default step timeout, default 24 hours
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Machine) {
+		m.ttl = ttl
+	}
+}
+
+// WithCancelCommands set the commands that leave the current FSM, default "/cancel"
+func WithCancelCommands(commands ...string) Option {
+	return func(m *Machine) {
+		m.cancelCommands = commands
+	}
+}
+
+// WithOnCancel set the handler called after the user cancels
+func WithOnCancel(fn tele.HandlerFunc) Option {
+	return func(m *Machine) {
+		m.onCancel = fn
+	}
+}
+
+// WithOnTimeout set the handler called when a message arrives after the step timed out
+func WithOnTimeout(fn tele.HandlerFunc) Option {
+	return func(m *Machine) {
+		m.onTimeout = fn
+	}
+}
+
+// New FSM runtime
+func New(options ...Option) *Machine {
+	m := &Machine{
+		handlers:       map[string]tele.HandlerFunc{},
+		prefix:         "fsm",
+		scope:          ScopeChatUser,
+		ttl:            24 * time.Hour,
+		cancelCommands: []string{"/cancel"},
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+// Register NextFn handlers, so that a state saved by any process can be dispatched.
+// Pass the BotFSMEvents generated by `codetool gen_bot_events`.
+func (m *Machine) Register(fns ...tele.HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		m.handlers[funcName(fn)] = fn
+	}
+}
+
+// handler get a registered NextFn by name
+func (m *Machine) handler(name string) (tele.HandlerFunc, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fn, ok := m.handlers[name]
+	return fn, ok
+}
+
+// SetFSMValue save the state of the current chat/user, the next message goes to value.NextFn
+func (m *Machine) SetFSMValue(c tele.Context, value FSMValue) error {
+	if value.NextFn == nil {
+		return errors.New("fsm: NextFn is nil")
+	}
+	key, err := m.key(c)
+	if err != nil {
+		return err
+	}
+
+	name := funcName(value.NextFn)
+	if _, ok := m.handler(name); !ok {
+		m.Register(value.NextFn)
+	}
+
+	timeout := value.Timeout
+	if timeout <= 0 {
+		timeout = m.ttl
+	}
+	state := fsmState{
+		Next:      name,
+		ExpiredAt: time.Now().Add(timeout).Unix(),
+	}
+	if value.Payload != nil {
+		if state.Payload, err = json.Marshal(value.Payload); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	// 多保留一段时间，以便超时后的消息可以触发 OnTimeout
+	return redis.Set(key, data, timeout+m.ttl).Err()
+}
+
+// GetFSMValue get the state of the current chat/user, nil if there is none
+func (m *Machine) GetFSMValue(c tele.Context) (*FSMValue, error) {
+	state, err := m.load(c)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	value := &FSMValue{
+		ExpiredAt: time.Unix(state.ExpiredAt, 0),
+	}
+	if len(state.Payload) > 0 {
+		value.Payload = state.Payload
+	}
+	fn, ok := m.handler(state.Next)
+	if !ok {
+		return value, fmt.Errorf("%w: %s", ErrNotRegistered, state.Next)
+	}
+	value.NextFn = fn
+	return value, nil
+}
+
+// ClearFSM remove the state of the current chat/user
+func (m *Machine) ClearFSM(c tele.Context) error {
+	key, err := m.key(c)
+	if err != nil {
+		return err
+	}
+	return redis.Del(key).Err()
+}
+
+// Middleware dispatch incoming messages to the registered NextFn of the current state.
+// Updates without a state are passed to the next handler.
+func (m *Machine) Middleware() tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			if c.Message() == nil || c.Callback() != nil {
+				return next(c)
+			}
+
+			state, err := m.load(c)
+			if err != nil {
+				log.Errorf("fsm: load state: %v", err)
+				return next(c)
+			}
+			if state == nil {
+				return next(c)
+			}
+
+			// 退出当前流程
+			if m.isCancel(c.Text()) {
+				if err := m.ClearFSM(c); err != nil {
+					return err
+				}
+				if m.onCancel != nil {
+					return m.onCancel(c)
+				}
+				return nil
+			}
+
+			// 当前步骤已超时
+			if time.Now().Unix() > state.ExpiredAt {
+				if err := m.ClearFSM(c); err != nil {
+					return err
+				}
+				if m.onTimeout != nil {
+					return m.onTimeout(c)
+				}
+				return next(c)
+			}
+
+			fn, ok := m.handler(state.Next)
+			if !ok {
+				log.Errorf("fsm: %s is not registered, state dropped", state.Next)
+				if err := m.ClearFSM(c); err != nil {
+					return err
+				}
+				return next(c)
+			}
+			c.Set("FSMPayload", state.Payload)
+			return fn(c)
+		}
+	}
+}
+
+// load the stored state, nil if there is none
+func (m *Machine) load(c tele.Context) (*fsmState, error) {
+	key, err := m.key(c)
+	if err != nil {
+		return nil, err
+	}
+	data, err := redis.Get(key).Bytes()
+	if err == redis.RedisNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := &fsmState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// isCancel check if text is a cancel command, "/cancel@MyBot" is accepted too
+func (m *Machine) isCancel(text string) bool {
+	text = strings.TrimSpace(text)
+	if i := strings.Index(text, "@"); i > -1 && strings.HasPrefix(text, "/") {
+		text = text[:i]
+	}
+	for _, command := range m.cancelCommands {
+		if strings.EqualFold(text, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// key build the redis key of the current chat/user
+func (m *Machine) key(c tele.Context) (string, error) {
+	var botID, chatID, userID int64
+	if bot, ok := c.Bot().(*tele.Bot); ok {
+		botID = helper.GetBotID(bot.Token)
+	}
+	if c.Chat() != nil {
+		chatID = c.Chat().ID
+	}
+	if c.Sender() != nil {
+		userID = c.Sender().ID
+	}
+	return m.buildKey(botID, chatID, userID)
+}
+
+// buildKey build the redis key by scope
+func (m *Machine) buildKey(botID, chatID, userID int64) (string, error) {
+	switch m.scope {
+	case ScopeChat:
+		if chatID == 0 {
+			return "", errors.New("fsm: no chat in context")
+		}
+		return fmt.Sprintf("%s:%d:c%d", m.prefix, botID, chatID), nil
+	case ScopeUser:
+		if userID == 0 {
+			return "", errors.New("fsm: no sender in context")
+		}
+		return fmt.Sprintf("%s:%d:u%d", m.prefix, botID, userID), nil
+	default:
+		if chatID == 0 || userID == 0 {
+			return "", errors.New("fsm: no chat or sender in context")
+		}
+		return fmt.Sprintf("%s:%d:%d:%d", m.prefix, botID, chatID, userID), nil
+	}
+}
+
+// funcName get the full name of a function, e.g. github.com/mylukin/app/app.AskName
+func funcName(fn tele.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+}
+
+var defaultMachine = New()
+
+// Default get the default FSM runtime
+func Default() *Machine {
+	return defaultMachine
+}
+
+// Register NextFn handlers to the default FSM runtime
+func Register(fns ...tele.HandlerFunc) {
+	defaultMachine.Register(fns...)
+}
+
+// SetFSMValue save the state with the default FSM runtime
+//
+//	fsm.SetFSMValue(c, fsm.FSMValue{NextFn: AskAge, Payload: signup, Timeout: 5 * time.Minute})
+func SetFSMValue(c tele.Context, value FSMValue) error {
+	return defaultMachine.SetFSMValue(c, value)
+}
+
+// GetFSMValue get the state with the default FSM runtime
+func GetFSMValue(c tele.Context) (*FSMValue, error) {
+	return defaultMachine.GetFSMValue(c)
+}
+
+// ClearFSM remove the state with the default FSM runtime
+func ClearFSM(c tele.Context) error {
+	return defaultMachine.ClearFSM(c)
+}
+
+// Middleware of the default FSM runtime
+func Middleware() tele.MiddlewareFunc {
+	return defaultMachine.Middleware()
+}
+
+// GetPayload decode the payload carried to the current step
+func GetPayload[T any](c tele.Context) (T, error) {
+	var payload T
+	var raw []byte
+	switch v := c.Get("FSMPayload").(type) {
+	case json.RawMessage:
+		raw = v
+	case []byte:
+		raw = v
+	}
+	if len(raw) == 0 {
+		return payload, errors.New("fsm: no payload")
+	}
+	err := json.Unmarshal(raw, &payload)
+	return payload, err
+}
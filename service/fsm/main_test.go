@@ -0,0 +1,92 @@
+package fsm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+func askName(c tele.Context) error { return nil }
+
+func TestBuildKey(t *testing.T) {
+	cases := []struct {
+		scope Scope
+		want  string
+	}{
+		{ScopeChatUser, "fsm:1:-100:7"},
+		{ScopeChat, "fsm:1:c-100"},
+		{ScopeUser, "fsm:1:u7"},
+	}
+	for _, tc := range cases {
+		key, err := New(WithScope(tc.scope)).buildKey(1, -100, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != tc.want {
+			t.Errorf("buildKey() = %s, want %s", key, tc.want)
+		}
+	}
+
+	if _, err := New().buildKey(1, -100, 0); err == nil {
+		t.Errorf("buildKey() should fail without a sender")
+	}
+	if _, err := New(WithScope(ScopeUser)).buildKey(1, 0, 7); err != nil {
+		t.Errorf("buildKey() error = %v", err)
+	}
+}
+
+func TestIsCancel(t *testing.T) {
+	m := New(WithCancelCommands("/cancel", "/stop"))
+	for _, text := range []string{"/cancel", " /CANCEL ", "/cancel@MyBot", "/stop"} {
+		if !m.isCancel(text) {
+			t.Errorf("isCancel(%q) = false, want true", text)
+		}
+	}
+	for _, text := range []string{"cancel", "/cancelled", "hi @cancel", ""} {
+		if m.isCancel(text) {
+			t.Errorf("isCancel(%q) = true, want false", text)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	m := New()
+	m.Register(askName, nil)
+
+	name := funcName(askName)
+	if !strings.HasSuffix(name, "fsm.askName") {
+		t.Errorf("funcName() = %s", name)
+	}
+	if _, ok := m.handler(name); !ok {
+		t.Errorf("handler(%s) not registered", name)
+	}
+}
+
+func TestGetPayload(t *testing.T) {
+	type signup struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	bot, err := tele.NewBot(tele.Settings{Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := bot.NewContext(tele.Update{Message: &tele.Message{Text: "18"}})
+
+	if _, err := GetPayload[signup](c); err == nil {
+		t.Errorf("GetPayload() should fail without payload")
+	}
+
+	raw, _ := json.Marshal(signup{Name: "Bob", Age: 18})
+	c.Set("FSMPayload", json.RawMessage(raw))
+	payload, err := GetPayload[signup](c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Name != "Bob" || payload.Age != 18 {
+		t.Errorf("GetPayload() = %+v", payload)
+	}
+}
@@ -0,0 +1,287 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/easy-i18n/i18n"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Bundle translations loaded from locales/*.json at runtime
+//
+// The files have the same format as the ones produced by easyi18n, the file
+// name is the language, e.g. "zh-hans.json". Messages are registered with the
+// default catalog so every Printer sees them, overriding the compiled ones.
+type Bundle struct {
+	fsys      fs.FS
+	dir       string
+	interval  time.Duration
+	onMissing func(lang, key string)
+
+	mu       sync.Mutex
+	messages atomic.Pointer[map[string]Message] // 语言 => 翻译
+	modTimes map[string]time.Time
+	reported sync.Map
+}
+
+// BundleOption configure a Bundle
+type BundleOption func(*Bundle)
+
+// WithWatchInterval set how often Watch checks the files, default 2s
+func WithWatchInterval(interval time.Duration) BundleOption {
+	return func(b *Bundle) {
+		b.interval = interval
+	}
+}
+
+// WithMissingHandler report keys that are missing in a language, each key
+// is reported once per language
+func WithMissingHandler(fn func(lang, key string)) BundleOption {
+	return func(b *Bundle) {
+		b.onMissing = fn
+	}
+}
+
+// active is the bundle used to detect missing keys at runtime
+var active atomic.Pointer[Bundle]
+
+// LoadDir load the bundle from a directory on disk
+func LoadDir(dir string, options ...BundleOption) (*Bundle, error) {
+	return LoadFS(os.DirFS(dir), ".", options...)
+}
+
+// LoadFS load the bundle from dir of fsys, e.g. an embed.FS
+func LoadFS(fsys fs.FS, dir string, options ...BundleOption) (*Bundle, error) {
+	b := &Bundle{
+		fsys:     fsys,
+		dir:      dir,
+		interval: 2 * time.Second,
+		modTimes: map[string]time.Time{},
+	}
+	for _, option := range options {
+		option(b)
+	}
+	b.messages.Store(&map[string]Message{})
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	active.Store(b)
+	return b, nil
+}
+
+// Reload read all files again, nothing is applied if any of them is invalid
+// JSON or contains an invalid ICU message. A key removed from a file is
+// rendered as the key itself, the source text, instead of a compiled translation.
+func (b *Bundle) Reload() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files, err := fs.Glob(b.fsys, path.Join(b.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	// 记录修改时间，无效的文件在再次修改前不会重复加载
+	modTimes := map[string]time.Time{}
+	for _, file := range files {
+		if info, err := fs.Stat(b.fsys, file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	b.modTimes = modTimes
+
+	messages := map[string]Message{}
+	for _, file := range files {
+		msg, err := readMessages(b.fsys, file)
+		if err != nil {
+			return err
+		}
+		if err := ValidateMessages(msg); err != nil {
+			return fmt.Errorf("i18n: %s: %w", file, err)
+		}
+		lang := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		messages[lang] = msg
+	}
+
+	// 先在单独的 catalog 中检查所有变化，全部有效后才写入
+	type change struct {
+		tag        language.Tag
+		key, value string
+	}
+	var changes []change
+	scratch := catalog.NewBuilder()
+	previous := *b.messages.Load()
+	for lang, msg := range messages {
+		tag := language.Make(lang)
+		for key, value := range msg {
+			if old, ok := previous[lang][key]; ok && old == value {
+				continue
+			}
+			if err := scratch.SetString(tag, key, value); err != nil {
+				return fmt.Errorf("i18n: %s: %q: %w", lang, key, err)
+			}
+			changes = append(changes, change{tag, key, value})
+		}
+	}
+	// 删除的 key 恢复为源语言文本，即 key 本身
+	for lang, msg := range previous {
+		for key := range msg {
+			if _, ok := messages[lang][key]; !ok {
+				changes = append(changes, change{language.Make(lang), key, key})
+			}
+		}
+	}
+	for _, c := range changes {
+		if err := message.SetString(c.tag, c.key, c.value); err != nil {
+			return err
+		}
+	}
+	b.messages.Store(&messages)
+
+	// 其他语言有而该语言没有的 key
+	for _, lang := range b.Languages() {
+		for other, msg := range messages {
+			if other == lang {
+				continue
+			}
+			for key := range msg {
+				if _, ok := messages[lang][key]; !ok {
+					b.missing(lang, key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readMessages read a locale file
+func readMessages(fsys fs.FS, file string) (Message, error) {
+	buf, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		return nil, err
+	}
+	msg := Message{}
+	if err := json.Unmarshal(buf, &msg); err != nil {
+		return nil, fmt.Errorf("i18n: %s: %w", file, err)
+	}
+	return msg, nil
+}
+
+// Watch reload the bundle when the files change, until ctx is done
+func (b *Bundle) Watch(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !b.changed() {
+				continue
+			}
+			if err := b.Reload(); err != nil {
+				log.Errorf("reload locales: %v", err)
+				continue
+			}
+			log.Infof("locales reloaded: %s", strings.Join(b.Languages(), ", "))
+		}
+	}
+}
+
+// changed check whether any file is added, removed or modified
+func (b *Bundle) changed() bool {
+	files, err := fs.Glob(b.fsys, path.Join(b.dir, "*.json"))
+	if err != nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(files) != len(b.modTimes) {
+		return true
+	}
+	for _, file := range files {
+		info, err := fs.Stat(b.fsys, file)
+		if err != nil {
+			return true
+		}
+		if modTime, ok := b.modTimes[file]; !ok || !modTime.Equal(info.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Languages loaded languages, lower case
+func (b *Bundle) Languages() []string {
+	messages := *b.messages.Load()
+	langs := make([]string, 0, len(messages))
+	for lang := range messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Lookup get the translation of key in lang
+func (b *Bundle) Lookup(lang, key string) (string, bool) {
+	msg, ok := (*b.messages.Load())[strings.ToLower(lang)]
+	if !ok {
+		return "", false
+	}
+	value, ok := msg[key]
+	return value, ok
+}
+
+// check report the key if lang is loaded but the key is not in it
+func (b *Bundle) check(lang, key string) {
+	lang = strings.ToLower(lang)
+	msg, ok := (*b.messages.Load())[lang]
+	if !ok {
+		return
+	}
+	if _, ok := msg[key]; !ok {
+		b.missing(lang, key)
+	}
+}
+
+func (b *Bundle) missing(lang, key string) {
+	if b.onMissing == nil {
+		return
+	}
+	if _, loaded := b.reported.LoadOrStore(lang+"\x00"+key, true); !loaded {
+		b.onMissing(lang, key)
+	}
+}
+
+// checkMissing report the message key of a Printf call to the active bundle
+func checkMissing(printer *i18n.Printer, format string, args []any) {
+	b := active.Load()
+	if b == nil || printer == nil {
+		return
+	}
+	key := format
+	if len(args) > 0 {
+		switch v := args[len(args)-1].(type) {
+		case i18n.Domain:
+			key = v.K + "." + format
+		case []i18n.PluralRule:
+			// 复数规则的文本单独作为 key
+			for _, rule := range v {
+				b.check(printer.String(), rule.Text)
+			}
+		}
+	}
+	b.check(printer.String(), key)
+}
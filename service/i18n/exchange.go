@@ -0,0 +1,306 @@
+package i18n
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Exchange formats understood by CAT tools
+const (
+	ExchangeXLIFF = "xliff"
+	ExchangePO    = "po"
+)
+
+// Catalog a source and a target locale file exchanged with translators
+//
+// Keys of the locale files are kept as is: PO uses them as msgid and XLIFF
+// stores them in the unit name, so imports merge back into the same keys.
+type Catalog struct {
+	SourceLang string
+	TargetLang string
+	Source     Message
+	Target     Message
+	Notes      map[string]string // key => 译者备注
+}
+
+// LoadCatalog read <sourceLang>.json and <targetLang>.json of dir,
+// a missing target file is an empty catalog
+func LoadCatalog(dir, sourceLang, targetLang string) (*Catalog, error) {
+	fsys := os.DirFS(dir)
+	source, err := readMessages(fsys, sourceLang+".json")
+	if err != nil {
+		return nil, err
+	}
+	target, err := readMessages(fsys, targetLang+".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		target, err = Message{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Catalog{
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Source:     source,
+		Target:     target,
+		Notes:      map[string]string{},
+	}, nil
+}
+
+// Save write the target messages to <targetLang>.json of dir in the easyi18n format
+func (c *Catalog) Save(dir string) error {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Target); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, c.TargetLang+".json"), buf.Bytes(), 0o644)
+}
+
+// Export write the catalog as XLIFF 2.0 or PO
+func (c *Catalog) Export(w io.Writer, format string) error {
+	switch format {
+	case ExchangeXLIFF:
+		return c.writeXLIFF(w)
+	case ExchangePO:
+		return c.writePO(w)
+	}
+	return fmt.Errorf("i18n: unknown exchange format %q", format)
+}
+
+// Import merge the translations of an XLIFF 2.0 or PO file into the target,
+// untranslated and fuzzy entries are skipped and keys not in the file are
+// kept. Returns the number of changed messages, invalid ICU translations
+// are skipped and reported in the error.
+func (c *Catalog) Import(r io.Reader, format string) (int, error) {
+	if c.Target == nil {
+		c.Target = Message{}
+	}
+	if c.Notes == nil {
+		c.Notes = map[string]string{}
+	}
+	switch format {
+	case ExchangeXLIFF:
+		return c.readXLIFF(r)
+	case ExchangePO:
+		return c.readPO(r)
+	}
+	return 0, fmt.Errorf("i18n: unknown exchange format %q", format)
+}
+
+// ExchangeFormat guess the format from the file extension, XLIFF by default
+func ExchangeFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".po", ".pot":
+		return ExchangePO
+	}
+	return ExchangeXLIFF
+}
+
+// TargetLanguage read the target language declared by an exchange file
+func TargetLanguage(data []byte, format string) (string, error) {
+	var lang string
+	switch format {
+	case ExchangeXLIFF:
+		doc, err := decodeXLIFF(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		lang = doc.TrgLang
+	case ExchangePO:
+		entries, err := parsePO(bytes.NewReader(data))
+		if err != nil {
+			return "", err
+		}
+		lang = poHeader(entries, "Language")
+	}
+	if lang == "" {
+		return "", errors.New("i18n: the file has no target language")
+	}
+	return strings.ToLower(lang), nil
+}
+
+// keys sorted keys of the source
+func (c *Catalog) keys() []string {
+	keys := make([]string, 0, len(c.Source))
+	for key := range c.Source {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sourceText the source message of key
+func (c *Catalog) sourceText(key string) string {
+	if text := c.Source[key]; text != "" {
+		return text
+	}
+	return key
+}
+
+// targetText the translation of key, empty if it is still the source text
+func (c *Catalog) targetText(key string) string {
+	text := c.Target[key]
+	if text == c.sourceText(key) && !sameLanguage(c.SourceLang, c.TargetLang) {
+		return ""
+	}
+	return text
+}
+
+// checkLanguage make sure the file is for the target language
+func (c *Catalog) checkLanguage(lang string) error {
+	if lang == "" {
+		return nil
+	}
+	if c.TargetLang == "" {
+		c.TargetLang = strings.ToLower(lang)
+		return nil
+	}
+	if !sameLanguage(lang, c.TargetLang) {
+		return fmt.Errorf("i18n: the file is for %q, not %q", lang, c.TargetLang)
+	}
+	return nil
+}
+
+// merge set a translation and its note, returns whether the translation
+// changed, invalid ICU messages are refused
+func (c *Catalog) merge(key, text, note string) (bool, error) {
+	if text == "" {
+		return false, nil
+	}
+	if err := ValidateMessage(text); IsICU(text) && err != nil {
+		return false, fmt.Errorf("i18n: %q: %w", key, err)
+	}
+	if note != "" {
+		c.Notes[key] = note
+	}
+	if old, ok := c.Target[key]; ok && old == text {
+		return false, nil
+	}
+	c.Target[key] = text
+	return true, nil
+}
+
+func sameLanguage(a, b string) bool {
+	return language.Make(a) == language.Make(b)
+}
+
+// unitID a stable XML id of the key
+func unitID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return "u" + hex.EncodeToString(sum[:6])
+}
+
+// pluralCase a selector of an ICU plural and its message
+type pluralCase struct {
+	selector string
+	text     string
+}
+
+// splitPlural split a message with a single ICU plural, without offset or
+// exact selectors, into a complete message per category, the form gettext
+// can represent. The text around the plural is copied into every case.
+func splitPlural(pattern string) (name string, cases []pluralCase, ok bool) {
+	if _, err := ParseMessage(pattern); err != nil {
+		return "", nil, false
+	}
+	start, end := -1, -1
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '{' {
+			continue
+		}
+		closing := closingBrace(pattern, i)
+		if closing < 0 {
+			return "", nil, false
+		}
+		if fields := strings.SplitN(pattern[i+1:closing], ",", 3); len(fields) == 3 && strings.TrimSpace(fields[1]) == "plural" {
+			if start >= 0 {
+				return "", nil, false
+			}
+			start, end = i, closing
+		}
+		i = closing
+	}
+	if start < 0 {
+		return "", nil, false
+	}
+	prefix, suffix := pattern[:start], pattern[end+1:]
+	fields := strings.SplitN(pattern[start+1:end], ",", 3)
+	name, rest := strings.TrimSpace(fields[0]), fields[2]
+	hasOther := false
+	for {
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			break
+		}
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return "", nil, false
+		}
+		selector := strings.TrimSpace(rest[:open])
+		closing := closingBrace(rest, open)
+		if closing < 0 || !validPluralSelector(selector) || strings.HasPrefix(selector, "=") {
+			return "", nil, false
+		}
+		hasOther = hasOther || selector == PluralOther
+		cases = append(cases, pluralCase{selector, prefix + rest[open+1:closing] + suffix})
+		rest = rest[closing+1:]
+	}
+	return name, cases, hasOther
+}
+
+// closingBrace find the brace closing the one at open
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// joinPlural build an ICU plural from its cases
+func joinPlural(name string, cases []pluralCase) string {
+	var b strings.Builder
+	b.WriteString("{" + name + ", plural,")
+	for _, c := range cases {
+		b.WriteString(" " + c.selector + " {" + c.text + "}")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// pluralText the message of a selector, falls back to other
+func pluralText(cases []pluralCase, selector string) string {
+	var other string
+	for _, c := range cases {
+		if c.selector == selector {
+			return c.text
+		}
+		if c.selector == PluralOther {
+			other = c.text
+		}
+	}
+	return other
+}
@@ -0,0 +1,422 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// FormatCompact format a number in the short form of the language,
+// e.g. 1.2K in English, 1.2万 in Chinese and 1,2 тыс. in Russian
+func FormatCompact[T any](ctx T, value float64) string {
+	return formatCompact(toTag(getPrinter(ctx)), value)
+}
+
+// FormatCurrency format an amount of the currency with an ISO 4217 code,
+// using the symbol, fraction digits and symbol position of the language
+func FormatCurrency[T any](ctx T, amount float64, code string) (string, error) {
+	return formatCurrency(toTag(getPrinter(ctx)), amount, code)
+}
+
+// FormatDate format the date of t in style, loc is the time zone, nil keeps t's own
+func FormatDate[T any](ctx T, t time.Time, style string, loc *time.Location) string {
+	return formatDate(toTag(getPrinter(ctx)), inLocation(t, loc), "date", style)
+}
+
+// FormatTime format the time of day of t in style, loc is the time zone, nil keeps t's own
+func FormatTime[T any](ctx T, t time.Time, style string, loc *time.Location) string {
+	return formatDate(toTag(getPrinter(ctx)), inLocation(t, loc), "time", style)
+}
+
+// FormatDateTime format the date and time of t in style, loc is the time zone, nil keeps t's own
+func FormatDateTime[T any](ctx T, t time.Time, style string, loc *time.Location) string {
+	return formatDate(toTag(getPrinter(ctx)), inLocation(t, loc), "datetime", style)
+}
+
+// FormatRelative format t relative to now, e.g. "3 hours ago" or "in 2 days"
+func FormatRelative[T any](ctx T, t, now time.Time) string {
+	return formatRelative(toTag(getPrinter(ctx)), t, now)
+}
+
+// ParseCompact parse a number formatted by FormatCompact in any supported
+// language, as well as Chinese numerals such as 三千五百, 两万 and 1.2万
+func ParseCompact[T any](ctx T, s string) (float64, error) {
+	return parseCompact(toTag(getPrinter(ctx)), s)
+}
+
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if loc != nil {
+		return t.In(loc)
+	}
+	return t
+}
+
+// formatCompact format value with the largest compact unit not above it
+func formatCompact(tag language.Tag, value float64) string {
+	data, tag := getLocale(tag)
+	printer := message.NewPrinter(tag)
+	units := append(append([]compactUnit(nil), data.compact...), compactUnit{value: 1})
+
+	abs := math.Abs(value)
+	for i, unit := range units {
+		if abs < unit.value && unit.value > 1 {
+			continue
+		}
+		rounded := math.Round(abs/unit.value*10) / 10
+		// 进位到更大的单位，如 999.96K => 1M
+		if i > 0 && rounded*unit.value >= units[i-1].value {
+			unit = units[i-1]
+			rounded = math.Round(abs/unit.value*10) / 10
+		}
+		result := printer.Sprint(number.Decimal(rounded, number.MaxFractionDigits(1))) + unit.suffix
+		if value < 0 && rounded != 0 {
+			result = "-" + result
+		}
+		return result
+	}
+	return ""
+}
+
+// formatCurrency format amount with the currency symbol
+func formatCurrency(tag language.Tag, amount float64, code string) (string, error) {
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		return "", fmt.Errorf("i18n: invalid currency code %q", code)
+	}
+	data, tag := getLocale(tag)
+	printer := message.NewPrinter(tag)
+	// x/text 总是输出 "符号 数字"，只取其中的符号
+	symbol, _, _ := strings.Cut(printer.Sprint(currency.Symbol(unit.Amount(0))), " ")
+	scale, _ := currency.Standard.Rounding(unit)
+	digits := printer.Sprint(number.Decimal(math.Abs(amount), number.Scale(scale)))
+
+	var result string
+	switch {
+	case data.symbolAfter:
+		result = digits + "\u00a0" + symbol
+	case strings.IndexFunc(symbol, func(r rune) bool { return !unicode.IsLetter(r) }) < 0:
+		// 符号为字母时需要空格，如 CHF 10.00
+		result = symbol + "\u00a0" + digits
+	default:
+		result = symbol + digits
+	}
+	if amount < 0 {
+		result = "-" + result
+	}
+	return result, nil
+}
+
+var patternTokenRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// formatDate format t as a "date", "time" or "datetime" in style
+func formatDate(tag language.Tag, t time.Time, typ, style string) string {
+	data, _ := getLocale(tag)
+	if _, ok := data.dates[style]; !ok {
+		style = StyleMedium
+	}
+	switch typ {
+	case "date":
+		return data.expand(data.dates[style], t)
+	case "time":
+		return data.expand(data.times[style], t)
+	}
+	return strings.NewReplacer(
+		"{date}", data.expand(data.dates[style], t),
+		"{time}", data.expand(data.times[style], t),
+	).Replace(data.dateTime)
+}
+
+// expand replace the tokens of a date or time pattern
+func (l *localeData) expand(pattern string, t time.Time) string {
+	return patternTokenRegex.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token[1 : len(token)-1] {
+		case "y":
+			return l.digits(strconv.Itoa(t.Year()))
+		case "yy":
+			return l.digits(fmt.Sprintf("%02d", t.Year()%100))
+		case "M":
+			return l.digits(strconv.Itoa(int(t.Month())))
+		case "MM":
+			return l.digits(fmt.Sprintf("%02d", int(t.Month())))
+		case "MMM":
+			return l.monthsShort[t.Month()-1]
+		case "MMMM":
+			return l.months[t.Month()-1]
+		case "d":
+			return l.digits(strconv.Itoa(t.Day()))
+		case "dd":
+			return l.digits(fmt.Sprintf("%02d", t.Day()))
+		case "EEEE":
+			return l.weekdays[t.Weekday()]
+		case "H":
+			return l.digits(strconv.Itoa(t.Hour()))
+		case "HH":
+			return l.digits(fmt.Sprintf("%02d", t.Hour()))
+		case "h":
+			hour := t.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			return l.digits(strconv.Itoa(hour))
+		case "mm":
+			return l.digits(fmt.Sprintf("%02d", t.Minute()))
+		case "ss":
+			return l.digits(fmt.Sprintf("%02d", t.Second()))
+		case "a":
+			if t.Hour() < 12 {
+				return l.am
+			}
+			return l.pm
+		case "z":
+			return t.Format("MST")
+		}
+		return token
+	})
+}
+
+// digits convert ASCII digits to the native digits of the language
+func (l *localeData) digits(s string) string {
+	if !l.native {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return '٠' + r - '0'
+		}
+		return r
+	}, s)
+}
+
+// formatRelative format t relative to now with the largest fitting unit
+func formatRelative(tag language.Tag, t, now time.Time) string {
+	data, tag := getLocale(tag)
+	d := t.Sub(now)
+	direction := 1
+	if d < 0 {
+		d, direction = -d, 0
+	}
+	if d < time.Second {
+		return data.now
+	}
+
+	day := 24 * time.Hour
+	var unit string
+	var count int64
+	switch {
+	case d < time.Minute:
+		unit, count = "second", int64(d/time.Second)
+	case d < time.Hour:
+		unit, count = "minute", int64(d/time.Minute)
+	case d < day:
+		unit, count = "hour", int64(d/time.Hour)
+	case d < 7*day:
+		unit, count = "day", int64(d/day)
+	case d < 30*day:
+		unit, count = "week", int64(d/(7*day))
+	case d < 365*day:
+		unit, count = "month", int64(d/(30*day))
+	default:
+		unit, count = "year", int64(d/(365*day))
+	}
+	m, err := compile(data.relative[unit][direction])
+	if err != nil {
+		return data.now
+	}
+	result, _ := m.Format(tag, Args{"n": count})
+	return result
+}
+
+var chineseDigits = map[rune]float64{
+	'零': 0, '〇': 0,
+	'一': 1, '壹': 1,
+	'二': 2, '贰': 2, '貳': 2, '两': 2, '兩': 2,
+	'三': 3, '叁': 3, '參': 3,
+	'四': 4, '肆': 4,
+	'五': 5, '伍': 5,
+	'六': 6, '陆': 6, '陸': 6,
+	'七': 7, '柒': 7,
+	'八': 8, '捌': 8,
+	'九': 9, '玖': 9,
+}
+
+var chineseUnits = map[rune]float64{
+	'十': 10, '拾': 10,
+	'百': 100, '佰': 100,
+	'千': 1e3, '仟': 1e3,
+	'万': 1e4, '萬': 1e4,
+	'亿': 1e8, '億': 1e8,
+	'兆': 1e12,
+}
+
+// compactSuffixes suffixes of all languages, longest first
+var compactSuffixes = sync.OnceValue(func() []compactUnit {
+	var suffixes []compactUnit
+	for _, data := range locales {
+		for _, unit := range data.compact {
+			suffix := strings.ToLower(strings.TrimSpace(unit.suffix))
+			if _, ok := chineseUnits[[]rune(suffix)[0]]; !ok {
+				suffixes = append(suffixes, compactUnit{unit.value, suffix})
+			}
+		}
+	}
+	sort.Slice(suffixes, func(i, j int) bool {
+		return len(suffixes[i].suffix) > len(suffixes[j].suffix)
+	})
+	return suffixes
+})
+
+// parseCompact parse a compact or Chinese number
+func parseCompact(tag language.Tag, s string) (float64, error) {
+	invalid := fmt.Errorf("i18n: invalid number %q", s)
+	text := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '٠' && r <= '٩':
+			return '0' + r - '٠'
+		case r >= '۰' && r <= '۹':
+			return '0' + r - '۰'
+		case r >= '０' && r <= '９':
+			return '0' + r - '０'
+		case r == '٫' || r == '．':
+			return '.'
+		case r == '٬' || r == '，':
+			return ','
+		case r == '−' || r == '负' || r == '負':
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(s))
+
+	sign := 1.0
+	if rest, ok := strings.CutPrefix(text, "-"); ok {
+		sign, text = -1, strings.TrimSpace(rest)
+	}
+	if text == "" {
+		return 0, invalid
+	}
+	if strings.IndexFunc(text, isChineseNumeral) >= 0 {
+		value, err := parseChinese(text)
+		if err != nil {
+			return 0, invalid
+		}
+		return sign * value, nil
+	}
+
+	multiplier := 1.0
+	lower := strings.ToLower(text)
+	for _, unit := range compactSuffixes() {
+		rest, ok := strings.CutSuffix(lower, unit.suffix)
+		if !ok {
+			// 允许省略缩写的点，如 тыс
+			rest, ok = strings.CutSuffix(lower, strings.TrimSuffix(unit.suffix, "."))
+		}
+		if ok {
+			multiplier, lower = unit.value, rest
+			break
+		}
+	}
+	lower = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(lower)
+	// 俄语等以逗号为小数点，此时点为千位分隔符
+	if data, _ := getLocale(tag); data.decimal == "," && strings.Contains(lower, ",") {
+		lower = strings.ReplaceAll(lower, ".", "")
+		lower = strings.Replace(lower, ",", ".", 1)
+	} else {
+		lower = strings.ReplaceAll(lower, ",", "")
+	}
+	value, err := strconv.ParseFloat(lower, 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, invalid
+	}
+	return sign * value * multiplier, nil
+}
+
+func isChineseNumeral(r rune) bool {
+	_, digit := chineseDigits[r]
+	_, unit := chineseUnits[r]
+	return digit || unit || r == '点' || r == '點'
+}
+
+// parseChinese parse Chinese numerals mixed with Arabic digits
+//
+// total 为亿以上的部分，section 为万以内已确定的部分，current 为当前数字
+func parseChinese(s string) (float64, error) {
+	var total, section, current, lastUnit float64
+	var hasCurrent bool
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r >= '0' && r <= '9' || r == '.' {
+			j := i
+			for j < len(runes) && (runes[j] >= '0' && runes[j] <= '9' || runes[j] == '.' || runes[j] == ',') {
+				j++
+			}
+			value, err := strconv.ParseFloat(strings.ReplaceAll(string(runes[i:j]), ",", ""), 64)
+			if err != nil || hasCurrent {
+				return 0, fmt.Errorf("unexpected %q", runes[i:j])
+			}
+			current, hasCurrent, lastUnit = value, true, 0
+			i = j - 1
+			continue
+		}
+		if digit, ok := chineseDigits[r]; ok {
+			if hasCurrent && digit != 0 {
+				return 0, fmt.Errorf("unexpected %q", r)
+			}
+			if digit == 0 {
+				lastUnit = 0
+				continue
+			}
+			current, hasCurrent = digit, true
+			continue
+		}
+		if r == '点' || r == '點' {
+			scale := 0.1
+			for i+1 < len(runes) {
+				digit, ok := chineseDigits[runes[i+1]]
+				if !ok {
+					break
+				}
+				current += digit * scale
+				scale /= 10
+				i++
+			}
+			hasCurrent, lastUnit = true, 0
+			continue
+		}
+		unit, ok := chineseUnits[r]
+		if !ok {
+			return 0, fmt.Errorf("unexpected %q", r)
+		}
+		// 省略的一，如 十五、万、一万亿
+		if !hasCurrent && (unit < 1e4 || unit == 1e4 && section == 0 || total+section == 0) {
+			current = 1
+		}
+		switch {
+		case unit < 1e4:
+			section += current * unit
+		case unit == 1e4:
+			total += (section + current) * unit
+			section = 0
+		default:
+			// 亿、兆作用于前面的全部数值，如 一万亿
+			total = (total + section + current) * unit
+			section = 0
+		}
+		current, hasCurrent, lastUnit = 0, false, unit
+	}
+	// 省略的末位单位，如 三千五 = 3500、一万五 = 15000
+	if hasCurrent && lastUnit >= 100 && current < 10 {
+		current *= lastUnit / 10
+	}
+	return total + section + current, nil
+}
@@ -0,0 +1,541 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+	"golang.org/x/text/number"
+)
+
+// Args named arguments of an ICU message
+type Args = map[string]any
+
+// icuRegex detects translations written in ICU syntax, e.g. "{count, plural, ...}" or "{name}"
+var icuRegex = regexp.MustCompile(`\{\s*[\p{L}\p{N}_.]+\s*[,}]`)
+
+// IsICU check whether the message uses ICU arguments
+func IsICU(msg string) bool {
+	return icuRegex.MatchString(msg)
+}
+
+type partKind int
+
+const (
+	partText partKind = iota
+	partArg
+	partPound // plural 中的 #
+	partPlural
+	partSelect
+)
+
+// part of a parsed message
+type part struct {
+	kind    partKind
+	text    string
+	name    string
+	typ     string // number, date, time
+	style   string
+	offset  float64
+	ordinal bool
+	cases   map[string][]part
+}
+
+// MessageFormat a parsed ICU message
+type MessageFormat struct {
+	pattern string
+	parts   []part
+}
+
+// ParseMessage parse an ICU MessageFormat pattern
+//
+// Supported are simple arguments "{name}", "{n, number[, integer|percent]}",
+// "{d, date|time[, short|medium|long|full]}", "{n, plural, ...}",
+// "{n, selectordinal, ...}" with "offset:" and "=N" selectors, and
+// "{g, select, ...}". Quoting follows ICU: "”" is a single quote and
+// "'{...}'" is literal text.
+func ParseMessage(pattern string) (*MessageFormat, error) {
+	p := &icuParser{src: []rune(pattern)}
+	parts, err := p.message(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return &MessageFormat{pattern: pattern, parts: parts}, nil
+}
+
+// ValidateMessage check that the pattern is valid ICU syntax
+func ValidateMessage(pattern string) error {
+	_, err := ParseMessage(pattern)
+	return err
+}
+
+type icuParser struct {
+	src []rune
+	pos int
+}
+
+func (p *icuParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("i18n: icu: "+format+" at %d", append(args, p.pos)...)
+}
+
+func (p *icuParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// word read a name, type or selector
+func (p *icuParser) word() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if unicode.IsSpace(c) || strings.ContainsRune("{},#'", c) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// message parse text and arguments until "}" or the end
+func (p *icuParser) message(inPlural bool) ([]part, error) {
+	parts := []part{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			parts = append(parts, part{kind: partText, text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\'':
+			p.pos++
+			if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+				text.WriteRune('\'')
+				p.pos++
+				continue
+			}
+			if p.pos < len(p.src) && (strings.ContainsRune("{}|", p.src[p.pos]) || (inPlural && p.src[p.pos] == '#')) {
+				// 引用的文本直到下一个单引号
+				for p.pos < len(p.src) {
+					if p.src[p.pos] == '\'' {
+						if p.pos+1 < len(p.src) && p.src[p.pos+1] == '\'' {
+							text.WriteRune('\'')
+							p.pos += 2
+							continue
+						}
+						p.pos++
+						break
+					}
+					text.WriteRune(p.src[p.pos])
+					p.pos++
+				}
+				continue
+			}
+			text.WriteRune('\'')
+		case c == '{':
+			flush()
+			arg, err := p.argument()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, arg)
+		case c == '}':
+			flush()
+			return parts, nil
+		case c == '#' && inPlural:
+			flush()
+			parts = append(parts, part{kind: partPound})
+			p.pos++
+		default:
+			text.WriteRune(c)
+			p.pos++
+		}
+	}
+	flush()
+	return parts, nil
+}
+
+// argument parse "{name[, type[, style]]}"
+func (p *icuParser) argument() (part, error) {
+	p.pos++ // {
+	p.skipSpace()
+	name := p.word()
+	if name == "" {
+		return part{}, p.errorf("missing argument name")
+	}
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return part{}, p.errorf("unclosed argument %q", name)
+	}
+	if p.src[p.pos] == '}' {
+		p.pos++
+		return part{kind: partArg, name: name}, nil
+	}
+	if p.src[p.pos] != ',' {
+		return part{}, p.errorf("unexpected %q in argument %q", p.src[p.pos], name)
+	}
+	p.pos++
+	p.skipSpace()
+	typ := p.word()
+	p.skipSpace()
+
+	switch typ {
+	case "plural", "selectordinal", "select":
+		if p.pos >= len(p.src) || p.src[p.pos] != ',' {
+			return part{}, p.errorf("missing cases of %q", name)
+		}
+		p.pos++
+		return p.cases(name, typ)
+	case "number", "date", "time":
+		arg := part{kind: partArg, name: name, typ: typ}
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			start := p.pos
+			for p.pos < len(p.src) && p.src[p.pos] != '}' {
+				p.pos++
+			}
+			arg.style = strings.TrimSpace(string(p.src[start:p.pos]))
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != '}' {
+			return part{}, p.errorf("unclosed argument %q", name)
+		}
+		p.pos++
+		return arg, nil
+	}
+	return part{}, p.errorf("unknown type %q of argument %q", typ, name)
+}
+
+// cases parse the selectors of plural, selectordinal and select
+func (p *icuParser) cases(name, typ string) (part, error) {
+	arg := part{kind: partSelect, name: name, cases: map[string][]part{}}
+	if typ != "select" {
+		arg.kind = partPlural
+		arg.ordinal = typ == "selectordinal"
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return part{}, p.errorf("unclosed argument %q", name)
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			break
+		}
+		selector := p.word()
+		if selector == "" {
+			return part{}, p.errorf("missing selector in %q", name)
+		}
+		if arg.kind == partPlural && strings.HasPrefix(selector, "offset:") {
+			offset, err := strconv.ParseFloat(strings.TrimPrefix(selector, "offset:"), 64)
+			if err != nil || len(arg.cases) > 0 {
+				return part{}, p.errorf("invalid %q in %q", selector, name)
+			}
+			arg.offset = offset
+			continue
+		}
+		if arg.kind == partPlural && !validPluralSelector(selector) {
+			return part{}, p.errorf("invalid plural selector %q in %q", selector, name)
+		}
+		if _, ok := arg.cases[selector]; ok {
+			return part{}, p.errorf("duplicate selector %q in %q", selector, name)
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+			return part{}, p.errorf("missing message of %q in %q", selector, name)
+		}
+		p.pos++
+		msg, err := p.message(arg.kind == partPlural)
+		if err != nil {
+			return part{}, err
+		}
+		if p.pos >= len(p.src) {
+			return part{}, p.errorf("unclosed message of %q in %q", selector, name)
+		}
+		p.pos++ // }
+		arg.cases[selector] = msg
+	}
+	if _, ok := arg.cases[PluralOther]; !ok {
+		return part{}, p.errorf("missing \"other\" in %q", name)
+	}
+	return arg, nil
+}
+
+func validPluralSelector(selector string) bool {
+	switch selector {
+	case PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther:
+		return true
+	}
+	if strings.HasPrefix(selector, "=") {
+		_, err := strconv.ParseFloat(selector[1:], 64)
+		return err == nil
+	}
+	return false
+}
+
+// Format the message in lang with named arguments
+//
+// Missing arguments are left as "{name}" and an error is returned along with
+// the best effort result.
+func (m *MessageFormat) Format(lang any, args Args) (string, error) {
+	f := &icuFormatter{tag: toTag(lang), args: args}
+	f.printer = message.NewPrinter(f.tag)
+	var out strings.Builder
+	f.parts(&out, m.parts, nil)
+	return out.String(), errors.Join(f.errs...)
+}
+
+// String is the pattern
+func (m *MessageFormat) String() string {
+	return m.pattern
+}
+
+type icuFormatter struct {
+	tag     language.Tag
+	printer *message.Printer
+	args    Args
+	errs    []error
+}
+
+// parts write the parts, pound is the value of the enclosing plural
+func (f *icuFormatter) parts(out *strings.Builder, parts []part, pound any) {
+	for _, p := range parts {
+		switch p.kind {
+		case partText:
+			out.WriteString(p.text)
+		case partPound:
+			out.WriteString(f.number(pound, ""))
+		case partArg:
+			value, ok := f.args[p.name]
+			if !ok {
+				f.errs = append(f.errs, fmt.Errorf("i18n: missing argument %q", p.name))
+				out.WriteString("{" + p.name + "}")
+				continue
+			}
+			out.WriteString(f.arg(p, value))
+		case partSelect:
+			value := f.args[p.name]
+			msg, ok := p.cases[fmt.Sprint(value)]
+			if !ok || value == nil {
+				msg = p.cases[PluralOther]
+			}
+			f.parts(out, msg, pound)
+		case partPlural:
+			value, ok := f.args[p.name]
+			if !ok {
+				f.errs = append(f.errs, fmt.Errorf("i18n: missing argument %q", p.name))
+			}
+			msg, rel := f.plural(p, value)
+			f.parts(out, msg, rel)
+		}
+	}
+}
+
+// plural select the case of a plural, returns the value minus offset for "#"
+func (f *icuFormatter) plural(p part, value any) ([]part, any) {
+	op, err := newOperands(value)
+	if err != nil {
+		f.errs = append(f.errs, err)
+		return p.cases[PluralOther], value
+	}
+	// 精确匹配优先
+	for selector, msg := range p.cases {
+		if !strings.HasPrefix(selector, "=") {
+			continue
+		}
+		if exact, err := strconv.ParseFloat(selector[1:], 64); err == nil && exact == op.n {
+			return msg, f.sub(value, p.offset)
+		}
+	}
+	rel := f.sub(value, p.offset)
+	category, _ := PluralCategory(f.tag, rel, p.ordinal)
+	if msg, ok := p.cases[category]; ok {
+		return msg, rel
+	}
+	return p.cases[PluralOther], rel
+}
+
+// sub subtract the plural offset keeping integers as integers
+func (f *icuFormatter) sub(value any, offset float64) any {
+	if offset == 0 {
+		return value
+	}
+	op, _ := newOperands(value)
+	n := op.n - offset
+	if op.v == 0 && n == float64(int64(n)) {
+		return int64(n)
+	}
+	return n
+}
+
+// arg format a simple argument
+func (f *icuFormatter) arg(p part, value any) string {
+	switch p.typ {
+	case "number":
+		return f.number(value, p.style)
+	case "date", "time":
+		if t, ok := value.(time.Time); ok {
+			return formatDate(f.tag, t, p.typ, p.style)
+		}
+	}
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return f.number(v, "")
+	}
+	return fmt.Sprint(value)
+}
+
+// number format a number with the grouping of the language
+func (f *icuFormatter) number(value any, style string) string {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	case string:
+		op, err := newOperands(value)
+		if err != nil {
+			return value.(string)
+		}
+		value = op.n
+	default:
+		return fmt.Sprint(value)
+	}
+	switch style {
+	case "integer":
+		return f.printer.Sprint(number.Decimal(value, number.MaxFractionDigits(0)))
+	case "percent":
+		return f.printer.Sprint(number.Percent(value))
+	case "compact":
+		op, _ := newOperands(value)
+		if strings.HasPrefix(fmt.Sprint(value), "-") {
+			op.n = -op.n
+		}
+		return formatCompact(f.tag, op.n)
+	}
+	return f.printer.Sprint(number.Decimal(value))
+}
+
+// messageCache parsed messages by pattern
+var messageCache sync.Map
+
+// compile parse the pattern once
+func compile(pattern string) (*MessageFormat, error) {
+	if m, ok := messageCache.Load(pattern); ok {
+		return m.(*MessageFormat), nil
+	}
+	m, err := ParseMessage(pattern)
+	if err != nil {
+		return nil, err
+	}
+	messageCache.Store(pattern, m)
+	return m, nil
+}
+
+// translate get the raw translation of key, the key itself if there is none
+func translate(tag language.Tag, key string) string {
+	r := &rawRenderer{}
+	if builder, ok := message.DefaultCatalog.(*catalog.Builder); ok {
+		if err := builder.Context(tag, r).Execute(key); err == nil {
+			return r.String()
+		}
+	}
+	return key
+}
+
+// rawRenderer collect the message without substituting arguments
+type rawRenderer struct {
+	strings.Builder
+}
+
+func (r *rawRenderer) Render(s string) {
+	r.WriteString(s)
+}
+
+func (r *rawRenderer) Arg(i int) any {
+	return nil
+}
+
+// SprintICU translate key to the language of ctx and format it as an ICU message
+func SprintICU[T any](ctx T, key string, args Args) string {
+	printer := getPrinter(ctx)
+	checkMissing(printer, key, nil)
+	tag := toTag(printer)
+	m, err := compile(translate(tag, key))
+	if err != nil {
+		// 翻译无效时使用原文
+		if m, err = compile(key); err != nil {
+			return key
+		}
+	}
+	result, _ := m.Format(tag, args)
+	return result
+}
+
+// PrintICU is like SprintICU but prints to stdout
+func PrintICU[T any](ctx T, key string, args Args) {
+	fmt.Print(SprintICU(ctx, key, args))
+}
+
+// FprintICU is like SprintICU but writes to w
+func FprintICU[T any](w io.Writer, ctx T, key string, args Args) (int, error) {
+	return io.WriteString(w, SprintICU(ctx, key, args))
+}
+
+// ValidateMessages check the ICU translations of one language
+func ValidateMessages(messages Message) error {
+	keys := make([]string, 0, len(messages))
+	for key := range messages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errs := []error{}
+	for _, key := range keys {
+		for _, msg := range []string{key, messages[key]} {
+			if !IsICU(msg) {
+				continue
+			}
+			if err := ValidateMessage(msg); err != nil {
+				errs = append(errs, fmt.Errorf("%q: %w", key, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateFS check the ICU translations of dir/*.json in fsys
+func ValidateFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, file := range files {
+		messages, err := readMessages(fsys, file)
+		if err == nil {
+			err = ValidateMessages(messages)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
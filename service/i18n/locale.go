@@ -0,0 +1,201 @@
+package i18n
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// compactUnit a power of ten with its short suffix
+type compactUnit struct {
+	value  float64
+	suffix string
+}
+
+// localeData formatting data of a language
+type localeData struct {
+	months      []string // 日期中使用的月份名，俄语为属格
+	monthsShort []string
+	weekdays    []string // 从星期日开始
+	am, pm      string
+	dates       map[string]string
+	times       map[string]string
+	dateTime    string
+	compact     []compactUnit // 从大到小
+	decimal     string        // 小数点
+	symbolAfter bool          // 货币符号在数字后
+	native      bool          // 使用本地数字
+	now         string
+	relative    map[string][2]string // 单位 => [过去, 将来] 的 ICU 消息
+}
+
+// Date and time styles
+const (
+	StyleShort  = "short"
+	StyleMedium = "medium"
+	StyleLong   = "long"
+	StyleFull   = "full"
+)
+
+// plural build an ICU plural message, forms are category and text pairs
+func plural(forms ...string) string {
+	var b strings.Builder
+	b.WriteString("{n, plural,")
+	for i := 0; i+1 < len(forms); i += 2 {
+		b.WriteString(" " + forms[i] + " {" + forms[i+1] + "}")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// arabic build the Arabic forms of a unit, one and two are complete phrases
+func arabic(prefix, one, two, few, many string) string {
+	return plural(
+		"one", prefix+one,
+		"two", prefix+two,
+		"few", prefix+"# "+few,
+		"many", prefix+"# "+many,
+		"other", prefix+"# "+many,
+	)
+}
+
+// russian build the Russian forms of a unit
+func russian(format, one, few, many string) string {
+	f := func(unit string) string {
+		return strings.Replace(format, "%s", "# "+unit, 1)
+	}
+	return plural("one", f(one), "few", f(few), "many", f(many), "other", f(few))
+}
+
+var locales = map[string]*localeData{
+	"en": {
+		months:      []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		monthsShort: []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:    []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		am:          "AM",
+		pm:          "PM",
+		dates:       map[string]string{StyleShort: "{M}/{d}/{yy}", StyleMedium: "{MMM} {d}, {y}", StyleLong: "{MMMM} {d}, {y}", StyleFull: "{EEEE}, {MMMM} {d}, {y}"},
+		times:       map[string]string{StyleShort: "{h}:{mm} {a}", StyleMedium: "{h}:{mm}:{ss} {a}", StyleLong: "{h}:{mm}:{ss} {a} {z}", StyleFull: "{h}:{mm}:{ss} {a} {z}"},
+		dateTime:    "{date}, {time}",
+		compact:     []compactUnit{{1e12, "T"}, {1e9, "B"}, {1e6, "M"}, {1e3, "K"}},
+		decimal:     ".",
+		now:         "now",
+		relative: map[string][2]string{
+			"year":   {plural("one", "# year ago", "other", "# years ago"), plural("one", "in # year", "other", "in # years")},
+			"month":  {plural("one", "# month ago", "other", "# months ago"), plural("one", "in # month", "other", "in # months")},
+			"week":   {plural("one", "# week ago", "other", "# weeks ago"), plural("one", "in # week", "other", "in # weeks")},
+			"day":    {plural("one", "# day ago", "other", "# days ago"), plural("one", "in # day", "other", "in # days")},
+			"hour":   {plural("one", "# hour ago", "other", "# hours ago"), plural("one", "in # hour", "other", "in # hours")},
+			"minute": {plural("one", "# minute ago", "other", "# minutes ago"), plural("one", "in # minute", "other", "in # minutes")},
+			"second": {plural("one", "# second ago", "other", "# seconds ago"), plural("one", "in # second", "other", "in # seconds")},
+		},
+	},
+	"zh-hans": {
+		months:      []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		monthsShort: []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:    []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		am:          "上午",
+		pm:          "下午",
+		dates:       map[string]string{StyleShort: "{y}/{M}/{d}", StyleMedium: "{y}年{M}月{d}日", StyleLong: "{y}年{M}月{d}日", StyleFull: "{y}年{M}月{d}日{EEEE}"},
+		times:       map[string]string{StyleShort: "{HH}:{mm}", StyleMedium: "{HH}:{mm}:{ss}", StyleLong: "{z} {HH}:{mm}:{ss}", StyleFull: "{z} {HH}:{mm}:{ss}"},
+		dateTime:    "{date} {time}",
+		compact:     []compactUnit{{1e12, "万亿"}, {1e8, "亿"}, {1e4, "万"}},
+		decimal:     ".",
+		now:         "刚刚",
+		relative: map[string][2]string{
+			"year":   {"{n}年前", "{n}年后"},
+			"month":  {"{n}个月前", "{n}个月后"},
+			"week":   {"{n}周前", "{n}周后"},
+			"day":    {"{n}天前", "{n}天后"},
+			"hour":   {"{n}小时前", "{n}小时后"},
+			"minute": {"{n}分钟前", "{n}分钟后"},
+			"second": {"{n}秒前", "{n}秒后"},
+		},
+	},
+	"zh-hant": {
+		months:      []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		monthsShort: []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:    []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		am:          "上午",
+		pm:          "下午",
+		dates:       map[string]string{StyleShort: "{y}/{M}/{d}", StyleMedium: "{y}年{M}月{d}日", StyleLong: "{y}年{M}月{d}日", StyleFull: "{y}年{M}月{d}日 {EEEE}"},
+		times:       map[string]string{StyleShort: "{a}{h}:{mm}", StyleMedium: "{a}{h}:{mm}:{ss}", StyleLong: "{a}{h}:{mm}:{ss} [{z}]", StyleFull: "{a}{h}:{mm}:{ss} [{z}]"},
+		dateTime:    "{date} {time}",
+		compact:     []compactUnit{{1e12, "兆"}, {1e8, "億"}, {1e4, "萬"}},
+		decimal:     ".",
+		now:         "剛剛",
+		relative: map[string][2]string{
+			"year":   {"{n} 年前", "{n} 年後"},
+			"month":  {"{n} 個月前", "{n} 個月後"},
+			"week":   {"{n} 週前", "{n} 週後"},
+			"day":    {"{n} 天前", "{n} 天後"},
+			"hour":   {"{n} 小時前", "{n} 小時後"},
+			"minute": {"{n} 分鐘前", "{n} 分鐘後"},
+			"second": {"{n} 秒前", "{n} 秒後"},
+		},
+	},
+	"ru": {
+		months:      []string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		monthsShort: []string{"янв.", "февр.", "мар.", "апр.", "мая", "июн.", "июл.", "авг.", "сент.", "окт.", "нояб.", "дек."},
+		weekdays:    []string{"воскресенье", "понедельник", "вторник", "среда", "четверг", "пятница", "суббота"},
+		am:          "AM",
+		pm:          "PM",
+		dates:       map[string]string{StyleShort: "{dd}.{MM}.{yy}", StyleMedium: "{d} {MMM} {y} г.", StyleLong: "{d} {MMMM} {y} г.", StyleFull: "{EEEE}, {d} {MMMM} {y} г."},
+		times:       map[string]string{StyleShort: "{HH}:{mm}", StyleMedium: "{HH}:{mm}:{ss}", StyleLong: "{HH}:{mm}:{ss} {z}", StyleFull: "{HH}:{mm}:{ss} {z}"},
+		dateTime:    "{date}, {time}",
+		compact:     []compactUnit{{1e12, "\u00a0трлн"}, {1e9, "\u00a0млрд"}, {1e6, "\u00a0млн"}, {1e3, "\u00a0тыс."}},
+		decimal:     ",",
+		symbolAfter: true,
+		now:         "сейчас",
+		relative: map[string][2]string{
+			"year":   {russian("%s назад", "год", "года", "лет"), russian("через %s", "год", "года", "лет")},
+			"month":  {russian("%s назад", "месяц", "месяца", "месяцев"), russian("через %s", "месяц", "месяца", "месяцев")},
+			"week":   {russian("%s назад", "неделю", "недели", "недель"), russian("через %s", "неделю", "недели", "недель")},
+			"day":    {russian("%s назад", "день", "дня", "дней"), russian("через %s", "день", "дня", "дней")},
+			"hour":   {russian("%s назад", "час", "часа", "часов"), russian("через %s", "час", "часа", "часов")},
+			"minute": {russian("%s назад", "минуту", "минуты", "минут"), russian("через %s", "минуту", "минуты", "минут")},
+			"second": {russian("%s назад", "секунду", "секунды", "секунд"), russian("через %s", "секунду", "секунды", "секунд")},
+		},
+	},
+	"ar": {
+		months:      []string{"يناير", "فبراير", "مارس", "أبريل", "مايو", "يونيو", "يوليو", "أغسطس", "سبتمبر", "أكتوبر", "نوفمبر", "ديسمبر"},
+		monthsShort: []string{"يناير", "فبراير", "مارس", "أبريل", "مايو", "يونيو", "يوليو", "أغسطس", "سبتمبر", "أكتوبر", "نوفمبر", "ديسمبر"},
+		weekdays:    []string{"الأحد", "الاثنين", "الثلاثاء", "الأربعاء", "الخميس", "الجمعة", "السبت"},
+		am:          "ص",
+		pm:          "م",
+		dates:       map[string]string{StyleShort: "{d}‏/{M}‏/{y}", StyleMedium: "{dd}‏/{MM}‏/{y}", StyleLong: "{d} {MMMM} {y}", StyleFull: "{EEEE}، {d} {MMMM} {y}"},
+		times:       map[string]string{StyleShort: "{h}:{mm} {a}", StyleMedium: "{h}:{mm}:{ss} {a}", StyleLong: "{h}:{mm}:{ss} {a} {z}", StyleFull: "{h}:{mm}:{ss} {a} {z}"},
+		dateTime:    "{date}، {time}",
+		compact:     []compactUnit{{1e12, " تريليون"}, {1e9, " مليار"}, {1e6, " مليون"}, {1e3, " ألف"}},
+		decimal:     "٫",
+		symbolAfter: true,
+		native:      true,
+		now:         "الآن",
+		relative: map[string][2]string{
+			"year":   {arabic("قبل ", "سنة واحدة", "سنتين", "سنوات", "سنة"), arabic("خلال ", "سنة واحدة", "سنتين", "سنوات", "سنة")},
+			"month":  {arabic("قبل ", "شهر واحد", "شهرين", "أشهر", "شهرًا"), arabic("خلال ", "شهر واحد", "شهرين", "أشهر", "شهرًا")},
+			"week":   {arabic("قبل ", "أسبوع واحد", "أسبوعين", "أسابيع", "أسبوعًا"), arabic("خلال ", "أسبوع واحد", "أسبوعين", "أسابيع", "أسبوعًا")},
+			"day":    {arabic("قبل ", "يوم واحد", "يومين", "أيام", "يومًا"), arabic("خلال ", "يوم واحد", "يومين", "أيام", "يومًا")},
+			"hour":   {arabic("قبل ", "ساعة واحدة", "ساعتين", "ساعات", "ساعة"), arabic("خلال ", "ساعة واحدة", "ساعتين", "ساعات", "ساعة")},
+			"minute": {arabic("قبل ", "دقيقة واحدة", "دقيقتين", "دقائق", "دقيقة"), arabic("خلال ", "دقيقة واحدة", "دقيقتين", "دقائق", "دقيقة")},
+			"second": {arabic("قبل ", "ثانية واحدة", "ثانيتين", "ثوانٍ", "ثانية"), arabic("خلال ", "ثانية واحدة", "ثانيتين", "ثوانٍ", "ثانية")},
+		},
+	},
+}
+
+// getLocale find the formatting data of a language, falls back to English
+// and returns the tag the data is for
+func getLocale(tag language.Tag) (*localeData, language.Tag) {
+	base, _ := tag.Base()
+	if base.String() == "zh" {
+		// 繁体：zh-Hant、zh-TW、zh-HK、zh-MO
+		if script, _ := tag.Script(); script.String() == "Hant" {
+			return locales["zh-hant"], tag
+		}
+		return locales["zh-hans"], tag
+	}
+	if data, ok := locales[base.String()]; ok {
+		return data, tag
+	}
+	return locales["en"], language.English
+}
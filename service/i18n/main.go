@@ -1,12 +1,9 @@
 package i18n
 
 import (
-	"fmt"
 	"io"
 
-	"github.com/labstack/echo/v4"
 	"github.com/mylukin/easy-i18n/i18n"
-	tele "gopkg.in/telebot.v4"
 )
 
 // Domain is domain
@@ -35,49 +32,26 @@ func Make(lang any) *i18n.Printer {
 
 // Printf is like fmt.Printf, but using language-specific formatting.
 func Printf[T any](ctx T, format string, args ...any) {
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Printf(format, args...)
-		}
-	}()
-	getPrinter(ctx).Printf(format, args...)
+	printer := getPrinter(ctx)
+	checkMissing(printer, format, args)
+	printer.Printf(format, args...)
 }
 
 // Sprintf is like fmt.Sprintf, but using language-specific formatting.
-func Sprintf[T any](ctx T, format string, args ...any) (result string) {
-	defer func() {
-		if err := recover(); err != nil {
-			result = fmt.Sprintf(format, args...)
-		}
-	}()
-	return getPrinter(ctx).Sprintf(format, args...)
+func Sprintf[T any](ctx T, format string, args ...any) string {
+	printer := getPrinter(ctx)
+	checkMissing(printer, format, args)
+	return printer.Sprintf(format, args...)
 }
 
 // Fprintf is like fmt.Fprintf, but using language-specific formatting.
-func Fprintf[T any](w io.Writer, ctx T, key string, args ...any) (n int, resErr error) {
-	defer func() {
-		if err := recover(); err != nil {
-			n, resErr = fmt.Fprintf(w, key, args...)
-		}
-	}()
-	return getPrinter(ctx).Fprintf(w, key, args...)
+func Fprintf[T any](w io.Writer, ctx T, key string, args ...any) (int, error) {
+	printer := getPrinter(ctx)
+	checkMissing(printer, key, args)
+	return printer.Fprintf(w, key, args...)
 }
 
 // Plural is plural
 func Plural(cases ...any) []i18n.PluralRule {
 	return i18n.Plural(cases...)
 }
-
-// getPrinter 是一个泛型函数，用于获取 i18n.Printer
-func getPrinter[T any](ctx T) *i18n.Printer {
-	switch c := any(ctx).(type) {
-	case echo.Context:
-		return c.Get("Language").(*i18n.Printer)
-	case tele.Context:
-		return c.Get("Language").(*i18n.Printer)
-	case *i18n.Printer:
-		return c
-	default:
-		panic("i18n ctx error")
-	}
-}
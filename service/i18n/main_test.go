@@ -1,8 +1,21 @@
 package i18n
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/labstack/echo/v4"
 )
 
 func TestPrintf(t *testing.T) {
@@ -10,3 +23,366 @@ func TestPrintf(t *testing.T) {
 	Printf(ctx, `test`)
 	fmt.Println()
 }
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("en.json", `{"Hello %s": "Hello %s", "Bye": "Bye"}`)
+	write("zh-hans.json", `{"Hello %s": "你好 %s"}`)
+
+	var mu sync.Mutex
+	missing := map[string]bool{}
+	bundle, err := LoadDir(dir, WithWatchInterval(10*time.Millisecond), WithMissingHandler(func(lang, key string) {
+		mu.Lock()
+		defer mu.Unlock()
+		missing[lang+":"+key] = true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Sprintf(Make("zh-hans"), "Hello %s", "Tom"); got != "你好 Tom" {
+		t.Errorf("Sprintf() = %q", got)
+	}
+	Sprintf(Make("en"), "Not translated")
+
+	mu.Lock()
+	if !missing["zh-hans:Bye"] || !missing["en:Not translated"] {
+		t.Errorf("missing = %v", missing)
+	}
+	mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bundle.Watch(ctx)
+
+	// 无效文件不生效
+	write("zh-hans.json", `{"Hello %s": `)
+	time.Sleep(50 * time.Millisecond)
+	if got := Sprintf(Make("zh-hans"), "Hello %s", "Tom"); got != "你好 Tom" {
+		t.Errorf("Sprintf() after invalid file = %q", got)
+	}
+
+	write("zh-hans.json", `{"Hello %s": "您好 %s", "Bye": "再见"}`)
+	deadline := time.Now().Add(2 * time.Second)
+	for Sprintf(Make("zh-hans"), "Bye") != "再见" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := Sprintf(Make("zh-hans"), "Hello %s", "Tom"); got != "您好 Tom" {
+		t.Errorf("Sprintf() after reload = %q", got)
+	}
+	// 删除的 key 不再使用旧的翻译
+	write("zh-hans.json", `{"Hello %s": "您好 %s"}`)
+	if err := bundle.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bundle.Lookup("zh-hans", "Bye"); ok {
+		t.Error("Lookup() found a removed key")
+	}
+	if got := Sprintf(Make("zh-hans"), "Bye"); got != "Bye" {
+		t.Errorf("Sprintf() of a removed key = %q", got)
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{"locales/zh-hant.json": {Data: []byte(`{"Bundle FS": "套件"}`)}}
+	bundle, err := LoadFS(fsys, "locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := bundle.Lookup("zh-Hant", "Bundle FS"); !ok || value != "套件" {
+		t.Errorf("Lookup() = %q, %v", value, ok)
+	}
+	if got := Sprintf(Make("zh-hant"), "Bundle FS"); got != "套件" {
+		t.Errorf("Sprintf() = %q", got)
+	}
+}
+
+func TestPluralCategory(t *testing.T) {
+	cases := []struct {
+		lang    string
+		value   any
+		ordinal bool
+		want    string
+	}{
+		{"en", 1, false, PluralOne},
+		{"en", "1.0", false, PluralOther},
+		{"en", 22, true, PluralTwo},
+		{"en", 13, true, PluralOther},
+		{"zh-hans", 1, false, PluralOther},
+		{"ru", 21, false, PluralOne},
+		{"ru", 3, false, PluralFew},
+		{"ru", 12, false, PluralMany},
+		{"ru", 1.5, false, PluralOther},
+		{"ar", 0, false, PluralZero},
+		{"ar", 2, false, PluralTwo},
+		{"ar", 105, false, PluralFew},
+		{"ar", 111, false, PluralMany},
+		{"ar", 100, false, PluralOther},
+	}
+	for _, tc := range cases {
+		if got, _ := PluralCategory(tc.lang, tc.value, tc.ordinal); got != tc.want {
+			t.Errorf("PluralCategory(%s, %v, %v) = %s, want %s", tc.lang, tc.value, tc.ordinal, got, tc.want)
+		}
+	}
+}
+
+func TestMessageFormat(t *testing.T) {
+	pattern := `{gender, select, female {She} male {He} other {They}} invited {count, plural, offset:1 =0 {nobody} =1 {{host}} one {{host} and # other} other {{host} and # others}} to the {day, selectordinal, one {#st} two {#nd} few {#rd} other {#th}} party, it''s '{free}'.`
+	m, err := ParseMessage(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args Args
+		want string
+	}{
+		{Args{"gender": "female", "count": 0, "host": "Ann", "day": 1}, "She invited nobody to the 1st party, it's {free}."},
+		{Args{"gender": "male", "count": 2, "host": "Ann", "day": 22}, "He invited Ann and 1 other to the 22nd party, it's {free}."},
+		{Args{"count": 1002, "host": "Ann", "day": 13}, "They invited Ann and 1,001 others to the 13th party, it's {free}."},
+	}
+	for _, tc := range cases {
+		if got, err := m.Format("en", tc.args); err != nil || got != tc.want {
+			t.Errorf("Format(%v) = %q, %v, want %q", tc.args, got, err, tc.want)
+		}
+	}
+	if got, err := m.Format("en", Args{"count": 1}); err == nil || !strings.Contains(got, "{host}") {
+		t.Errorf("Format() with missing args = %q, %v", got, err)
+	}
+
+	for _, invalid := range []string{`{n, plural, one {x}}`, `{n, plural, single {x} other {y}}`, `{n, select, a {x} other {y}`, `{n, bogus}`, `a } b`} {
+		if err := ValidateMessage(invalid); err == nil {
+			t.Errorf("ValidateMessage(%q) = nil", invalid)
+		}
+	}
+}
+
+func TestSprintICU(t *testing.T) {
+	key := "You have {count, plural, one {# new message} other {# new messages}}"
+	fsys := fstest.MapFS{
+		"ru.json": {Data: []byte(`{"` + key + `": "У вас {count, plural, one {# новое сообщение} few {# новых сообщения} many {# новых сообщений} other {# нового сообщения}}"}`)},
+	}
+	if _, err := LoadFS(fsys, "."); err != nil {
+		t.Fatal(err)
+	}
+	if got := SprintICU(Make("en"), key, Args{"count": 1}); got != "You have 1 new message" {
+		t.Errorf("SprintICU(en) = %q", got)
+	}
+	if got := SprintICU(Make("ru"), key, Args{"count": 3}); got != "У вас 3 новых сообщения" {
+		t.Errorf("SprintICU(ru) = %q", got)
+	}
+
+	// 无效的 ICU 翻译不会加载
+	fsys["ru.json"] = &fstest.MapFile{Data: []byte(`{"` + key + `": "{count, plural, one {x}"}`)}
+	if _, err := LoadFS(fsys, "."); err == nil {
+		t.Error("LoadFS() with invalid ICU message = nil")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tm := time.Date(2024, 3, 5, 15, 4, 5, 0, time.UTC)
+	shanghai := time.FixedZone("CST", 8*3600)
+	cases := []struct {
+		got, want string
+	}{
+		{FormatCompact(Make("en"), 1234), "1.2K"},
+		{FormatCompact(Make("en"), 999960), "1M"},
+		{FormatCompact(Make("en"), -15), "-15"},
+		{FormatCompact(Make("zh-hans"), 12345678), "1,234.6万"},
+		{FormatCompact(Make("zh-TW"), 3.2e12), "3.2兆"},
+		{FormatCompact(Make("ru"), 1234), "1,2\u00a0тыс."},
+		{FormatDate(Make("en"), tm, StyleFull, nil), "Tuesday, March 5, 2024"},
+		{FormatDate(Make("ru"), tm, StyleLong, nil), "5 марта 2024 г."},
+		{FormatDateTime(Make("zh-hans"), tm, StyleShort, shanghai), "2024/3/5 23:04"},
+		{FormatTime(Make("zh-hant"), tm, StyleMedium, shanghai), "下午11:04:05"},
+		{FormatTime(Make("ar"), tm, StyleShort, nil), "٣:٠٤ م"},
+		{FormatRelative(Make("en"), tm.Add(-3*time.Hour), tm), "3 hours ago"},
+		{FormatRelative(Make("en"), tm.Add(-8*24*time.Hour), tm), "1 week ago"},
+		{FormatRelative(Make("zh-hans"), tm.Add(48*time.Hour), tm), "2天后"},
+		{FormatRelative(Make("ru"), tm.Add(-21*time.Second), tm), "21 секунду назад"},
+		{FormatRelative(Make("ru"), tm.Add(150*24*time.Hour), tm), "через 5 месяцев"},
+		{FormatRelative(Make("ar"), tm.Add(48*time.Hour), tm), "خلال يومين"},
+		{FormatRelative(Make("fr"), tm, tm), "now"},
+		{SprintICU(Make("zh-hans"), "{d, date, long} {n, number, compact}", Args{"d": tm, "n": 25300}), "2024年3月5日 2.5万"},
+	}
+	for i, tc := range cases {
+		if tc.got != tc.want {
+			t.Errorf("case %d = %q, want %q", i, tc.got, tc.want)
+		}
+	}
+
+	currencies := []struct {
+		lang   string
+		amount float64
+		code   string
+		want   string
+	}{
+		{"en", -1234.5, "USD", "-$1,234.50"},
+		{"en", 1234.5, "JPY", "¥1,234"},
+		{"en", 10, "CHF", "CHF\u00a010.00"},
+		{"zh-hans", 1234.5, "USD", "US$1,234.50"},
+		{"ru", 1234.5, "USD", "1\u00a0234,50\u00a0$"},
+	}
+	for _, tc := range currencies {
+		if got, err := FormatCurrency(Make(tc.lang), tc.amount, tc.code); err != nil || got != tc.want {
+			t.Errorf("FormatCurrency(%s, %v, %s) = %q, %v, want %q", tc.lang, tc.amount, tc.code, got, err, tc.want)
+		}
+	}
+	if _, err := FormatCurrency(Make("en"), 1, "XYZW"); err == nil {
+		t.Error("FormatCurrency() with invalid code = nil")
+	}
+}
+
+func TestParseCompact(t *testing.T) {
+	cases := []struct {
+		lang, s string
+		want    float64
+	}{
+		{"en", "1.2K", 1200},
+		{"en", "-2k", -2000},
+		{"en", "1,234.5", 1234.5},
+		{"zh-hans", "1.2万", 12000},
+		{"zh-hans", "三千五百", 3500},
+		{"zh-hans", "三千五", 3500},
+		{"zh-hans", "三千零五", 3005},
+		{"zh-hans", "十五", 15},
+		{"zh-hans", "两万", 20000},
+		{"zh-hans", "一亿二千万", 1.2e8},
+		{"zh-hans", "1万亿", 1e12},
+		{"zh-hans", "三点一四", 3.14},
+		{"zh-hant", "1,234.6萬", 12346000},
+		{"ru", "2,5 тыс", 2500},
+		{"ru", "1\u00a0234,5", 1234.5},
+		{"ar", "١٫٢ ألف", 1200},
+	}
+	for _, tc := range cases {
+		if got, err := ParseCompact(Make(tc.lang), tc.s); err != nil || math.Abs(got-tc.want) > 1e-6 {
+			t.Errorf("ParseCompact(%s, %q) = %v, %v, want %v", tc.lang, tc.s, got, err, tc.want)
+		}
+	}
+	for _, invalid := range []string{"", "abc", "五五", "1.2.3K"} {
+		if _, err := ParseCompact(Make("en"), invalid); err == nil {
+			t.Errorf("ParseCompact(%q) = nil", invalid)
+		}
+	}
+}
+
+func TestExchange(t *testing.T) {
+	plural := "You have {count, plural, one {# new message} other {# new messages}}"
+	newCatalog := func() *Catalog {
+		return &Catalog{
+			SourceLang: "en",
+			TargetLang: "ru",
+			Source:     Message{"Hello %s": "Hello %s", "Bye": "Bye", "Two\nlines": "Two\nlines", plural: plural},
+			Target:     Message{"Hello %s": "Привет %s", "Bye": "Bye", "Obsolete": "Старый"},
+			Notes:      map[string]string{"Bye": "Keep it short"},
+		}
+	}
+
+	var po strings.Builder
+	if err := newCatalog().Export(&po, ExchangePO); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Language: ru", "# Keep it short\nmsgid \"Bye\"\nmsgstr \"\"", "#, c-format\nmsgid \"Hello %s\"\nmsgstr \"Привет %s\"", "msgid \"\"\n\"Two\\n\"\n\"lines\"", "msgid \"You have # new message\"\nmsgid_plural \"You have # new messages\"\nmsgstr[0] \"\"\nmsgstr[1] \"\"\nmsgstr[2] \"\""} {
+		if !strings.Contains(po.String(), want) {
+			t.Errorf("PO has no %q:\n%s", want, po.String())
+		}
+	}
+
+	translated := strings.NewReplacer(
+		"msgstr[0] \"\"", "msgstr[0] \"У вас # новое сообщение\"",
+		"msgstr[1] \"\"", "msgstr[1] \"У вас # новых сообщения\"",
+		"msgstr[2] \"\"", "msgstr[2] \"У вас # новых сообщений\"",
+		"# Keep it short\nmsgid \"Bye\"\nmsgstr \"\"", "# Formal\nmsgid \"Bye\"\nmsgstr \"До свидания\"",
+	).Replace(po.String())
+	c := newCatalog()
+	if lang, err := TargetLanguage([]byte(translated), ExchangePO); err != nil || lang != "ru" {
+		t.Errorf("TargetLanguage() = %q, %v", lang, err)
+	}
+	changed, err := c.Import(strings.NewReader(translated), ExchangePO)
+	if err != nil || changed != 2 {
+		t.Fatalf("Import(po) = %d, %v", changed, err)
+	}
+	if got := c.Target["Bye"]; got != "До свидания" || c.Notes["Bye"] != "Formal" {
+		t.Errorf("Bye = %q, note %q", got, c.Notes["Bye"])
+	}
+	if c.Target["Obsolete"] != "Старый" {
+		t.Error("Import() lost a key")
+	}
+	m, err := ParseMessage(c.Target[plural])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.Format("ru", Args{"count": 5}); got != "У вас 5 новых сообщений" {
+		t.Errorf("plural = %q", got)
+	}
+
+	var xliff strings.Builder
+	if err := c.Export(&xliff, ExchangeXLIFF); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(xliff.String(), `trgLang="ru"`) || !strings.Contains(xliff.String(), "<note>Formal</note>") {
+		t.Errorf("XLIFF:\n%s", xliff.String())
+	}
+	edited := strings.Replace(xliff.String(), "<target>До свидания</target>", "<target>Пока</target>", 1)
+	if changed, err := c.Import(strings.NewReader(edited), ExchangeXLIFF); err != nil || changed != 1 || c.Target["Bye"] != "Пока" {
+		t.Errorf("Import(xliff) = %d, %v, Bye = %q", changed, err, c.Target["Bye"])
+	}
+
+	invalid := strings.Replace(edited, "<target>Пока</target>", "<target>{n, plural, one {x}</target>", 1)
+	if _, err := c.Import(strings.NewReader(invalid), ExchangeXLIFF); err == nil || c.Target["Bye"] != "Пока" {
+		t.Errorf("Import() with invalid ICU = %v", err)
+	}
+	if _, err := (&Catalog{TargetLang: "zh-hans"}).Import(strings.NewReader(edited), ExchangeXLIFF); err == nil {
+		t.Error("Import() for another language = nil")
+	}
+}
+
+func TestResolvePrinter(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	c := e.NewContext(req.WithContext(WithPrinter(req.Context(), Make("ru"))), httptest.NewRecorder())
+	if got := getPrinter(c).String(); got != "ru" {
+		t.Errorf("echo request context = %s", got)
+	}
+	c.Set("Language", Make("zh-hans"))
+	if got := getPrinter(c).String(); got != "zh-hans" {
+		t.Errorf("echo Language = %s", got)
+	}
+	if got := getPrinter(WithPrinter(context.Background(), Make("ar"))).String(); got != "ar" {
+		t.Errorf("context.Context = %s", got)
+	}
+
+	SetDefaultLanguage("zh-hant")
+	defer SetDefaultLanguage("en")
+	if got := getPrinter(context.Background()).String(); got != "zh-hant" {
+		t.Errorf("default = %s", got)
+	}
+	var nilPrinter *Printer
+	if got := getPrinter(nilPrinter).String(); got != "zh-hant" {
+		t.Errorf("nil printer = %s", got)
+	}
+	if n := testing.AllocsPerRun(100, func() { getPrinter(context.Background()) }); n != 0 {
+		t.Errorf("fallback allocs = %v", n)
+	}
+
+	_, err := ResolvePrinter(42)
+	var resolveErr *ResolveError
+	if !errors.As(err, &resolveErr) || resolveErr.Type != "int" || !strings.Contains(resolveErr.Caller, "main_test.go") {
+		t.Errorf("ResolvePrinter(42) = %v", err)
+	}
+
+	SetStrict(true)
+	defer SetStrict(false)
+	defer func() {
+		err, _ := recover().(*ResolveError)
+		if err == nil || !strings.Contains(err.Error(), "main_test.go") || !strings.Contains(err.Error(), "echo") {
+			t.Errorf("strict mode panic = %v", err)
+		}
+	}()
+	Sprintf(e.NewContext(req, httptest.NewRecorder()), "Hello")
+	t.Error("Sprintf() in strict mode did not panic")
+}
@@ -0,0 +1,156 @@
+package i18n
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// CLDR plural categories
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// operands of a number as defined by CLDR
+type operands struct {
+	n float64 // 绝对值
+	i int64   // 整数部分
+	v int     // 可见小数位数
+	f int64   // 可见小数
+	t int64   // 去掉末尾 0 的小数
+}
+
+// newOperands get the operands of an int, float or numeric string
+func newOperands(value any) (operands, error) {
+	var s string
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = fmt.Sprint(v)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return operands{}, fmt.Errorf("i18n: %v (%T) is not a number", value, value)
+	}
+	s = strings.TrimPrefix(s, "-")
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return operands{}, fmt.Errorf("i18n: %q is not a number", s)
+	}
+	op := operands{n: math.Abs(n)}
+	integer, fraction, _ := strings.Cut(s, ".")
+	op.i, _ = strconv.ParseInt(integer, 10, 64)
+	if fraction != "" {
+		op.v = len(fraction)
+		op.f, _ = strconv.ParseInt(fraction, 10, 64)
+		op.t, _ = strconv.ParseInt(strings.TrimRight(fraction, "0"), 10, 64)
+	}
+	return op, nil
+}
+
+// inRange check whether x is an integer within [from, to]
+func inRange(x float64, from, to float64) bool {
+	return x == math.Trunc(x) && x >= from && x <= to
+}
+
+// pluralRule select the category of a number
+type pluralRule func(op operands) string
+
+var cardinalRules = map[string]pluralRule{
+	"en": func(op operands) string {
+		if op.i == 1 && op.v == 0 {
+			return PluralOne
+		}
+		return PluralOther
+	},
+	"zh": func(op operands) string {
+		return PluralOther
+	},
+	"ru": func(op operands) string {
+		if op.v != 0 {
+			return PluralOther
+		}
+		i10, i100 := op.i%10, op.i%100
+		switch {
+		case i10 == 1 && i100 != 11:
+			return PluralOne
+		case i10 >= 2 && i10 <= 4 && (i100 < 12 || i100 > 14):
+			return PluralFew
+		}
+		return PluralMany
+	},
+	"ar": func(op operands) string {
+		n100 := math.Mod(op.n, 100)
+		switch {
+		case op.n == 0:
+			return PluralZero
+		case op.n == 1:
+			return PluralOne
+		case op.n == 2:
+			return PluralTwo
+		case inRange(n100, 3, 10):
+			return PluralFew
+		case inRange(n100, 11, 99):
+			return PluralMany
+		}
+		return PluralOther
+	},
+}
+
+var ordinalRules = map[string]pluralRule{
+	"en": func(op operands) string {
+		n10, n100 := math.Mod(op.n, 10), math.Mod(op.n, 100)
+		switch {
+		case n10 == 1 && n100 != 11:
+			return PluralOne
+		case n10 == 2 && n100 != 12:
+			return PluralTwo
+		case n10 == 3 && n100 != 13:
+			return PluralFew
+		}
+		return PluralOther
+	},
+}
+
+// PluralCategory get the CLDR plural category of value in lang,
+// languages without rules only have "other"
+func PluralCategory(lang any, value any, ordinal bool) (string, error) {
+	op, err := newOperands(value)
+	if err != nil {
+		return PluralOther, err
+	}
+	rules := cardinalRules
+	if ordinal {
+		rules = ordinalRules
+	}
+	base, _ := toTag(lang).Base()
+	if rule, ok := rules[base.String()]; ok {
+		return rule(op), nil
+	}
+	return PluralOther, nil
+}
+
+// toTag convert a language tag, string or Printer to a language.Tag
+func toTag(lang any) language.Tag {
+	switch v := lang.(type) {
+	case language.Tag:
+		return v
+	case string:
+		return language.Make(v)
+	case *Printer:
+		return language.Make(v.String())
+	}
+	return language.English
+}
@@ -0,0 +1,274 @@
+package i18n
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// poPluralForm the gettext plural forms of a language, in msgstr[N] order
+type poPluralForm struct {
+	categories []string
+	expr       string
+}
+
+var poPluralForms = map[string]poPluralForm{
+	"en": {[]string{PluralOne, PluralOther}, "nplurals=2; plural=(n != 1);"},
+	"zh": {[]string{PluralOther}, "nplurals=1; plural=0;"},
+	"ru": {[]string{PluralOne, PluralFew, PluralMany}, "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);"},
+	"ar": {[]string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}, "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);"},
+}
+
+func poPlural(lang string) poPluralForm {
+	base, _ := language.Make(lang).Base()
+	if form, ok := poPluralForms[base.String()]; ok {
+		return form
+	}
+	return poPluralForms["en"]
+}
+
+// poEntry a message of a PO file
+type poEntry struct {
+	comments []string // 译者备注 "# "
+	flags    []string
+	obsolete bool
+	ctxt     string
+	id       string
+	idPlural string
+	str      []string
+}
+
+func (e *poEntry) fuzzy() bool {
+	for _, flag := range e.flags {
+		if flag == "fuzzy" {
+			return true
+		}
+	}
+	return false
+}
+
+var formatVerbRegex = regexp.MustCompile(`%[-+# 0]*(\d+|\*)?(\.(\d+|\*))?[vTtbcdoqxXUeEfFgGsp]`)
+
+var poEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// writePOString write a keyword and a string, multi-line strings are split after "\n"
+func writePOString(w *bufio.Writer, keyword, s string) {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= 1 {
+		fmt.Fprintf(w, "%s \"%s\"\n", keyword, poEscaper.Replace(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for _, line := range lines {
+		fmt.Fprintf(w, "\"%s\"\n", poEscaper.Replace(line))
+	}
+}
+
+// writePO write one entry per source key, single ICU plurals become
+// msgid_plural with msgstr[N] in the plural order of the target language
+func (c *Catalog) writePO(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	form := poPlural(c.TargetLang)
+	writePOString(w, "msgid", "")
+	writePOString(w, "msgstr", strings.Join([]string{
+		"Language: " + c.TargetLang,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+		"Plural-Forms: " + form.expr,
+		"X-Source-Language: " + c.SourceLang,
+	}, "\n")+"\n")
+
+	for _, key := range c.keys() {
+		w.WriteString("\n")
+		if note := c.Notes[key]; note != "" {
+			for _, line := range strings.Split(note, "\n") {
+				w.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+			}
+		}
+		if formatVerbRegex.MatchString(key) {
+			w.WriteString("#, c-format\n")
+		}
+		if _, cases, ok := splitPlural(key); ok {
+			writePOString(w, "msgid", pluralText(cases, PluralOne))
+			writePOString(w, "msgid_plural", pluralText(cases, PluralOther))
+			_, targets, translated := splitPlural(c.targetText(key))
+			for i, category := range form.categories {
+				var text string
+				if translated {
+					text = pluralText(targets, category)
+				}
+				writePOString(w, fmt.Sprintf("msgstr[%d]", i), text)
+			}
+			continue
+		}
+		writePOString(w, "msgid", key)
+		writePOString(w, "msgstr", c.targetText(key))
+	}
+	return w.Flush()
+}
+
+// parsePO read the entries of a PO file
+func parsePO(r io.Reader) ([]*poEntry, error) {
+	var entries []*poEntry
+	entry := &poEntry{}
+	var last *string // 续行追加的字段
+	started := false // 已读到 msgid
+	flush := func() {
+		if started {
+			entries = append(entries, entry)
+		}
+		entry, last, started = &poEntry{}, nil, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if started && len(entry.str) > 0 {
+				flush()
+			}
+			switch {
+			case strings.HasPrefix(line, "#~"):
+				entry.obsolete = true
+			case strings.HasPrefix(line, "#,"):
+				for _, flag := range strings.Split(line[2:], ",") {
+					entry.flags = append(entry.flags, strings.TrimSpace(flag))
+				}
+			case line == "#" || strings.HasPrefix(line, "# "):
+				entry.comments = append(entry.comments, strings.TrimPrefix(strings.TrimPrefix(line, "#"), " "))
+			}
+			continue
+		}
+
+		keyword, value, _ := strings.Cut(line, " ")
+		if strings.HasPrefix(line, `"`) {
+			keyword, value = "", line
+		}
+		s, err := strconv.Unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("i18n: po line %d: invalid string %s", number, value)
+		}
+		switch {
+		case keyword == "":
+			if last == nil {
+				return nil, fmt.Errorf("i18n: po line %d: unexpected string", number)
+			}
+			*last += s
+		case keyword == "msgctxt", keyword == "msgid":
+			if started && (keyword == "msgctxt" || entry.id != "" || len(entry.str) > 0) {
+				flush()
+			}
+			if keyword == "msgctxt" {
+				entry.ctxt, last = s, &entry.ctxt
+			} else {
+				entry.id, last, started = s, &entry.id, true
+			}
+		case keyword == "msgid_plural":
+			entry.idPlural, last = s, &entry.idPlural
+		case keyword == "msgstr", strings.HasPrefix(keyword, "msgstr["):
+			entry.str = append(entry.str, s)
+			last = &entry.str[len(entry.str)-1]
+		default:
+			return nil, fmt.Errorf("i18n: po line %d: unknown keyword %q", number, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return entries, nil
+}
+
+// poHeader get a field of the header entry
+func poHeader(entries []*poEntry, field string) string {
+	for _, entry := range entries {
+		if entry.id != "" || len(entry.str) == 0 {
+			continue
+		}
+		for _, line := range strings.Split(entry.str[0], "\n") {
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), field) {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+// readPO merge the translations, plural entries are matched with the
+// source keys by their msgid and msgid_plural
+func (c *Catalog) readPO(r io.Reader) (int, error) {
+	entries, err := parsePO(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.checkLanguage(poHeader(entries, "Language")); err != nil {
+		return 0, err
+	}
+
+	type pluralKey struct{ one, other string }
+	plurals := map[pluralKey]string{}
+	for key := range c.Source {
+		if _, cases, ok := splitPlural(key); ok {
+			plurals[pluralKey{pluralText(cases, PluralOne), pluralText(cases, PluralOther)}] = key
+		}
+	}
+	form := poPlural(c.TargetLang)
+
+	changed := 0
+	var errs []error
+	merge := func(key, text, note string) {
+		ok, err := c.merge(key, text, note)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ok {
+			changed++
+		}
+	}
+	for _, entry := range entries {
+		if entry.id == "" || entry.obsolete || entry.fuzzy() || len(entry.str) == 0 {
+			continue
+		}
+		note := strings.Join(entry.comments, "\n")
+		if entry.idPlural == "" {
+			merge(entry.id, entry.str[0], note)
+			continue
+		}
+
+		key, ok := plurals[pluralKey{entry.id, entry.idPlural}]
+		if !ok || len(entry.str) < len(form.categories) {
+			continue
+		}
+		name, _, _ := splitPlural(key)
+		var cases []pluralCase
+		complete := true
+		for i, category := range form.categories {
+			complete = complete && entry.str[i] != ""
+			cases = append(cases, pluralCase{category, entry.str[i]})
+		}
+		if !complete {
+			continue
+		}
+		// ICU 要求 other，如俄语的 other 使用 few 的形式
+		if pluralText(cases, PluralOther) == "" {
+			cases = append(cases, pluralCase{PluralOther, cases[min(1, len(cases)-1)].text})
+		}
+		merge(key, joinPlural(name, cases), note)
+	}
+	return changed, errors.Join(errs...)
+}
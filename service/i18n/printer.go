@@ -0,0 +1,138 @@
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/easy-i18n/i18n"
+	tele "gopkg.in/telebot.v4"
+)
+
+// ResolveError explain why no printer was found for a context
+type ResolveError struct {
+	Type   string // 上下文的类型
+	Reason string
+	Caller string // 调用 i18n 的位置 file:line
+}
+
+// Error is the error message
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("i18n: no printer for %s at %s: %s", e.Type, e.Caller, e.Reason)
+}
+
+type printerKey struct{}
+
+var (
+	defaultPrinter atomic.Pointer[i18n.Printer]
+	strict         atomic.Bool
+)
+
+func init() {
+	// 与语言中间件的默认语言一致
+	defaultPrinter.Store(i18n.NewPrinter(helper.Config("LANGUAGE", "en")))
+}
+
+// SetDefaultLanguage set the language used when a context has no printer,
+// default the LANGUAGE environment variable or "en"
+func SetDefaultLanguage(lang any) {
+	defaultPrinter.Store(i18n.NewPrinter(lang))
+}
+
+// SetStrict panic with a *ResolveError instead of falling back to the
+// default language, for tests
+func SetStrict(on bool) {
+	strict.Store(on)
+}
+
+// WithPrinter return a copy of ctx carrying the printer
+func WithPrinter(ctx context.Context, printer *i18n.Printer) context.Context {
+	return context.WithValue(ctx, printerKey{}, printer)
+}
+
+// PrinterFrom get the printer carried by ctx
+func PrinterFrom(ctx context.Context) (*i18n.Printer, bool) {
+	printer, ok := ctx.Value(printerKey{}).(*i18n.Printer)
+	return printer, ok && printer != nil
+}
+
+// ResolvePrinter find the printer of ctx, in order: *Printer, the "Language"
+// of echo.Context and tele.Context, the printer of context.Context (also the
+// request context of echo.Context)
+func ResolvePrinter[T any](ctx T) (*i18n.Printer, error) {
+	printer, reason := resolve(ctx)
+	if printer == nil {
+		return nil, &ResolveError{Type: fmt.Sprintf("%T", ctx), Reason: reason, Caller: caller()}
+	}
+	return printer, nil
+}
+
+// resolve the printer of ctx without building an error, reason tells why
+// the printer is nil
+func resolve[T any](ctx T) (*i18n.Printer, string) {
+	switch c := any(ctx).(type) {
+	case *i18n.Printer:
+		if c != nil {
+			return c, ""
+		}
+		return nil, "nil printer"
+	case echo.Context:
+		if printer, ok := c.Get("Language").(*i18n.Printer); ok && printer != nil {
+			return printer, ""
+		}
+		if req := c.Request(); req != nil {
+			if printer, ok := PrinterFrom(req.Context()); ok {
+				return printer, ""
+			}
+		}
+		return nil, `"Language" is not set, is the language middleware installed?`
+	case tele.Context:
+		if printer, ok := c.Get("Language").(*i18n.Printer); ok && printer != nil {
+			return printer, ""
+		}
+		return nil, `"Language" is not set, is the language middleware installed?`
+	case context.Context:
+		if c != nil {
+			if printer, ok := PrinterFrom(c); ok {
+				return printer, ""
+			}
+		}
+		return nil, "no printer in context, use WithPrinter"
+	}
+	return nil, "unsupported context type"
+}
+
+// getPrinter resolve the printer of ctx, falling back to the default
+// language unless strict mode is on
+func getPrinter[T any](ctx T) *i18n.Printer {
+	if printer, _ := resolve(ctx); printer != nil {
+		return printer
+	}
+	if strict.Load() {
+		// 只在严格模式下生成错误，调用位置的查找开销较大
+		_, err := ResolvePrinter(ctx)
+		panic(err)
+	}
+	return defaultPrinter.Load()
+}
+
+// caller the first frame outside of this package, tests of the package count as outside
+func caller() string {
+	prefix := reflect.TypeOf(printerKey{}).PkgPath() + "."
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, prefix) || strings.HasPrefix(frame.Function, prefix+"Test") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package i18n
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+type xliffDoc struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID    string      `xml:"id,attr"`
+	Units []xliffUnit `xml:"unit"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Name     string         `xml:"name,attr,omitempty"`
+	Notes    *xliffNotes    `xml:"notes,omitempty"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffNotes struct {
+	Notes []string `xml:"note"`
+}
+
+type xliffSegment struct {
+	State  string `xml:"state,attr,omitempty"`
+	Source string `xml:"source"`
+	Target string `xml:"target,omitempty"`
+}
+
+// writeXLIFF write one unit per source key, the key is the unit name
+func (c *Catalog) writeXLIFF(w io.Writer) error {
+	file := xliffFile{ID: "messages"}
+	for _, key := range c.keys() {
+		unit := xliffUnit{ID: unitID(key), Name: key}
+		if note := c.Notes[key]; note != "" {
+			unit.Notes = &xliffNotes{Notes: []string{note}}
+		}
+		segment := xliffSegment{State: "initial", Source: c.sourceText(key), Target: c.targetText(key)}
+		if segment.Target != "" {
+			segment.State = "translated"
+		}
+		unit.Segments = []xliffSegment{segment}
+		file.Units = append(file.Units, unit)
+	}
+	doc := xliffDoc{Version: "2.0", SrcLang: c.SourceLang, TrgLang: c.TargetLang, Files: []xliffFile{file}}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func decodeXLIFF(r io.Reader) (*xliffDoc, error) {
+	doc := &xliffDoc{}
+	if err := xml.NewDecoder(r).Decode(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// readXLIFF merge the targets, units are matched by name, id and then source
+func (c *Catalog) readXLIFF(r io.Reader) (int, error) {
+	doc, err := decodeXLIFF(r)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.checkLanguage(doc.TrgLang); err != nil {
+		return 0, err
+	}
+
+	byID := map[string]string{}
+	bySource := map[string]string{}
+	for key := range c.Source {
+		byID[unitID(key)] = key
+		bySource[c.sourceText(key)] = key
+	}
+
+	changed := 0
+	var errs []error
+	for _, file := range doc.Files {
+		for _, unit := range file.Units {
+			var source, target strings.Builder
+			for _, segment := range unit.Segments {
+				source.WriteString(segment.Source)
+				target.WriteString(segment.Target)
+			}
+			key := unit.Name
+			if key == "" {
+				key = byID[unit.ID]
+			}
+			if key == "" {
+				key = bySource[source.String()]
+			}
+			if key == "" {
+				key = source.String()
+			}
+			var note string
+			if unit.Notes != nil {
+				note = strings.Join(unit.Notes.Notes, "\n")
+			}
+			ok, err := c.merge(key, target.String(), note)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			if ok {
+				changed++
+			}
+		}
+	}
+	return changed, errors.Join(errs...)
+}
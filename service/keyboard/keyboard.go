@@ -0,0 +1,75 @@
+package keyboard
+
+import (
+	"errors"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+// Keyboard builds an inline keyboard with signed callback data
+//
+//	kb := keyboard.New()
+//	kb.Row(kb.Button("👍", "vote", Vote{PostID: 42, Up: true}), kb.URL("Docs", "https://example.com"))
+//	markup, err := kb.Markup()
+type Keyboard struct {
+	codec *Codec
+	rows  [][]tele.InlineButton
+	errs  []error
+}
+
+// New keyboard with the default codec
+func New() *Keyboard {
+	return Default().Keyboard()
+}
+
+// Keyboard create a keyboard with this codec
+func (codec *Codec) Keyboard() *Keyboard {
+	return &Keyboard{codec: codec}
+}
+
+// Button with callback data of action and payload
+func (kb *Keyboard) Button(text, action string, payload any) tele.InlineButton {
+	data, err := kb.codec.Encode(action, payload)
+	if err != nil {
+		kb.errs = append(kb.errs, err)
+	}
+	return tele.InlineButton{Text: text, Data: data}
+}
+
+// URL button
+func (kb *Keyboard) URL(text, url string) tele.InlineButton {
+	return tele.InlineButton{Text: text, URL: url}
+}
+
+// Row append a row of buttons
+func (kb *Keyboard) Row(buttons ...tele.InlineButton) *Keyboard {
+	if len(buttons) > 0 {
+		kb.rows = append(kb.rows, buttons)
+	}
+	return kb
+}
+
+// Grid append buttons, columns per row
+func (kb *Keyboard) Grid(columns int, buttons ...tele.InlineButton) *Keyboard {
+	if columns <= 0 {
+		columns = 1
+	}
+	for i := 0; i < len(buttons); i += columns {
+		end := min(i+columns, len(buttons))
+		kb.Row(buttons[i:end]...)
+	}
+	return kb
+}
+
+// Inline get the rows of buttons
+func (kb *Keyboard) Inline() ([][]tele.InlineButton, error) {
+	return kb.rows, errors.Join(kb.errs...)
+}
+
+// Markup get the reply markup, fails if any button could not be encoded
+func (kb *Keyboard) Markup() (*tele.ReplyMarkup, error) {
+	if err := errors.Join(kb.errs...); err != nil {
+		return nil, err
+	}
+	return &tele.ReplyMarkup{InlineKeyboard: kb.rows}, nil
+}
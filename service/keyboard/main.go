@@ -0,0 +1,366 @@
+package keyboard
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/storage/redis"
+)
+
+// MaxCallbackData is the limit of Telegram callback_data in bytes
+const MaxCallbackData = 64
+
+var (
+	// ErrInvalidSignature callback data is tampered or signed by another secret
+	ErrInvalidSignature = errors.New("keyboard: invalid callback signature")
+	// ErrExpired the spilled payload is no longer in redis
+	ErrExpired = errors.New("keyboard: callback payload expired")
+	// ErrTooLong the action is too long to fit in callback data
+	ErrTooLong = errors.New("keyboard: callback data too long")
+)
+
+const (
+	// sigLen is the number of HMAC bytes kept, 8 chars after base64
+	sigLen = 6
+	// spillMark marks a payload stored in redis
+	spillMark = '~'
+)
+
+// Codec encodes typed payloads into signed callback data
+//
+// The format is "action|payload|sig", payload is the struct fields in order,
+// separated by ",", zero values are left empty and trailing ones are dropped.
+type Codec struct {
+	secret []byte
+	prefix string
+	ttl    time.Duration
+}
+
+type Option func(*Codec)
+
+// WithPrefix set the redis key prefix of spilled payloads, default "cb"
+func WithPrefix(prefix string) Option {
+	return func(codec *Codec) {
+		codec.prefix = prefix
+	}
+}
+
+// WithTTL set how long a spilled payload is kept, default 7 days
+func WithTTL(ttl time.Duration) Option {
+	return func(codec *Codec) {
+		codec.ttl = ttl
+	}
+}
+
+// NewCodec create a codec signing with secret
+func NewCodec(secret string, options ...Option) *Codec {
+	codec := &Codec{
+		secret: []byte(secret),
+		prefix: "cb",
+		ttl:    7 * 24 * time.Hour,
+	}
+	for _, option := range options {
+		option(codec)
+	}
+	return codec
+}
+
+var (
+	defaultCodec *Codec
+	defaultOnce  sync.Once
+)
+
+// Default get the codec signing with CALLBACK_SECRET
+func Default() *Codec {
+	defaultOnce.Do(func() {
+		defaultCodec = NewCodec(helper.Config("CALLBACK_SECRET"))
+	})
+	return defaultCodec
+}
+
+// Callback is verified callback data
+type Callback struct {
+	Action  string
+	Payload string
+}
+
+// Bind decode the payload into v, v must be a pointer
+func (cb *Callback) Bind(v any) error {
+	return unmarshal(cb.Payload, v)
+}
+
+// Encode action and payload into callback data, payloads too large are spilled to redis
+func (codec *Codec) Encode(action string, v any) (string, error) {
+	if len(codec.secret) == 0 {
+		return "", errors.New("keyboard: secret is empty, set CALLBACK_SECRET")
+	}
+	if action == "" || strings.Contains(action, "|") {
+		return "", fmt.Errorf("keyboard: bad action %q", action)
+	}
+	payload, err := marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	data := codec.sign(action, payload)
+	if len(data) <= MaxCallbackData {
+		return data, nil
+	}
+
+	// 超过 64 字节，payload 存入 redis
+	id := make([]byte, 9)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	key := base64.RawURLEncoding.EncodeToString(id)
+	if err := redis.Set(codec.key(key), payload, codec.ttl).Err(); err != nil {
+		return "", err
+	}
+	data = codec.sign(action, string(spillMark)+key)
+	if len(data) > MaxCallbackData {
+		return "", ErrTooLong
+	}
+	return data, nil
+}
+
+// Decode verify callback data and load the spilled payload
+func (codec *Codec) Decode(data string) (*Callback, error) {
+	i := strings.Index(data, "|")
+	j := strings.LastIndex(data, "|")
+	if i < 0 || i == j {
+		return nil, ErrInvalidSignature
+	}
+	action, payload, sig := data[:i], data[i+1:j], data[j+1:]
+	if !hmac.Equal([]byte(sig), []byte(codec.signature(action, payload))) {
+		return nil, ErrInvalidSignature
+	}
+
+	if len(payload) > 0 && payload[0] == spillMark {
+		value, err := redis.Get(codec.key(payload[1:])).Result()
+		if err == redis.RedisNil {
+			return nil, ErrExpired
+		}
+		if err != nil {
+			return nil, err
+		}
+		payload = value
+	}
+	return &Callback{Action: action, Payload: payload}, nil
+}
+
+// sign join action and payload with the signature
+func (codec *Codec) sign(action, payload string) string {
+	return action + "|" + payload + "|" + codec.signature(action, payload)
+}
+
+// signature truncated HMAC-SHA256 of action and payload
+func (codec *Codec) signature(action, payload string) string {
+	mac := helper.HMAC(sha256.New, []byte(action+"|"+payload), codec.secret)
+	return base64.RawURLEncoding.EncodeToString(mac[:sigLen])
+}
+
+// key of a spilled payload
+func (codec *Codec) key(id string) string {
+	return codec.prefix + ":" + id
+}
+
+// marshal encode v compactly, structs are encoded by field order
+func marshal(v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return "", nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return marshalValue(rv)
+	}
+
+	fields := []string{}
+	for _, i := range structFields(rv.Type()) {
+		field, err := marshalValue(rv.Field(i))
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, field)
+	}
+	// 去掉末尾的零值
+	for len(fields) > 0 && fields[len(fields)-1] == "" {
+		fields = fields[:len(fields)-1]
+	}
+	return strings.Join(fields, ","), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// marshalValue encode one value, zero value is empty
+func marshalValue(rv reflect.Value) (string, error) {
+	if rv.IsZero() {
+		return "", nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		return marshalValue(rv.Elem())
+	case reflect.String:
+		return escape(rv.String()), nil
+	case reflect.Bool:
+		return "1", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 36), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 36), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()), nil
+	}
+	buf, err := json.Marshal(rv.Interface())
+	if err != nil {
+		return "", err
+	}
+	return escape(string(buf)), nil
+}
+
+// unmarshal decode data into v
+func unmarshal(data string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("keyboard: Bind needs a non-nil pointer")
+	}
+	rv = rv.Elem()
+
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return unmarshalValue(unescape(data), rv)
+	}
+
+	fields := split(data)
+	for n, i := range structFields(rv.Type()) {
+		if n >= len(fields) {
+			break
+		}
+		if err := unmarshalValue(unescape(fields[n]), rv.Field(i)); err != nil {
+			return fmt.Errorf("keyboard: field %s: %w", rv.Type().Field(i).Name, err)
+		}
+	}
+	return nil
+}
+
+// unmarshalValue decode one value, empty is zero value
+func unmarshalValue(data string, rv reflect.Value) error {
+	if data == "" {
+		rv.SetZero()
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(rv.Type().Elem())
+		if err := unmarshalValue(data, elem.Elem()); err != nil {
+			return err
+		}
+		rv.Set(elem)
+	case reflect.String:
+		rv.SetString(data)
+	case reflect.Bool:
+		rv.SetBool(data == "1")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(data, 36, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(data, 36, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(data, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(n)
+	default:
+		return json.Unmarshal([]byte(data), rv.Addr().Interface())
+	}
+	return nil
+}
+
+// structFields exported fields, skip `cb:"-"`
+func structFields(t reflect.Type) []int {
+	fields := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("cb") == "-" {
+			continue
+		}
+		fields = append(fields, i)
+	}
+	return fields
+}
+
+// escape "\", "," and "~"
+func escape(s string) string {
+	if !strings.ContainsAny(s, `\,~`) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\\' || r == ',' || r == '~' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescape revert escape
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// split data by unescaped ","
+func split(data string) []string {
+	fields := []string{}
+	start := 0
+	escaped := false
+	for i := 0; i < len(data); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case data[i] == '\\':
+			escaped = true
+		case data[i] == ',':
+			fields = append(fields, data[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, data[start:])
+}
@@ -0,0 +1,154 @@
+package keyboard
+
+import (
+	"strings"
+	"testing"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+type vote struct {
+	PostID int64
+	Up     bool
+	Note   string
+	Tags   []string
+	Skip   string `cb:"-"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	codec := NewCodec("secret")
+
+	data, err := codec.Encode("vote", vote{PostID: 1234567, Up: true, Note: `a,b\c~`, Tags: []string{"x"}, Skip: "skip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > MaxCallbackData {
+		t.Fatalf("Encode() = %d bytes, want <= %d", len(data), MaxCallbackData)
+	}
+	if !strings.HasPrefix(data, "vote|qglj,1,") {
+		t.Errorf("Encode() = %s", data)
+	}
+
+	cb, err := codec.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got vote
+	if err := cb.Bind(&got); err != nil {
+		t.Fatal(err)
+	}
+	if cb.Action != "vote" || got.PostID != 1234567 || !got.Up || got.Note != `a,b\c~` || len(got.Tags) != 1 || got.Skip != "" {
+		t.Errorf("Decode() = %s %+v", cb.Action, got)
+	}
+}
+
+func TestCodecZeroValues(t *testing.T) {
+	codec := NewCodec("secret")
+
+	data, err := codec.Encode("vote", &vote{PostID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(data, "vote|1|") {
+		t.Errorf("Encode() = %s, trailing zero values should be dropped", data)
+	}
+
+	data, err = codec.Encode("page", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cb, err := codec.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var page int
+	if err := cb.Bind(&page); err != nil || page != 3 {
+		t.Errorf("Bind() = %d, %v", page, err)
+	}
+}
+
+func TestCodecTamper(t *testing.T) {
+	data, err := NewCodec("secret").Encode("vote", vote{PostID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []string{
+		strings.Replace(data, "vote|1", "vote|2", 1),
+		strings.Replace(data, "vote|", "admin|", 1),
+		"vote|1",
+		"",
+	} {
+		if _, err := NewCodec("secret").Decode(bad); err != ErrInvalidSignature {
+			t.Errorf("Decode(%q) error = %v, want ErrInvalidSignature", bad, err)
+		}
+	}
+	if _, err := NewCodec("other").Decode(data); err != ErrInvalidSignature {
+		t.Errorf("Decode() with another secret error = %v", err)
+	}
+	if _, err := NewCodec("").Encode("vote", nil); err == nil {
+		t.Errorf("Encode() should fail without secret")
+	}
+}
+
+func TestKeyboard(t *testing.T) {
+	kb := NewCodec("secret").Keyboard()
+	kb.Grid(2,
+		kb.Button("1", "page", 1),
+		kb.Button("2", "page", 2),
+		kb.Button("3", "page", 3),
+	).Row(kb.URL("Docs", "https://example.com"))
+
+	markup, err := kb.Markup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := markup.InlineKeyboard
+	if len(rows) != 3 || len(rows[0]) != 2 || len(rows[1]) != 1 || rows[2][0].URL == "" {
+		t.Errorf("Markup() = %+v", rows)
+	}
+
+	kb.Row(kb.Button("bad", "a|b", nil))
+	if _, err := kb.Markup(); err == nil {
+		t.Errorf("Markup() should fail for a bad action")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	codec := NewCodec("secret")
+	router := NewRouter(codec)
+
+	var got vote
+	Handle(router, "vote", func(c tele.Context, payload vote) error {
+		got = payload
+		return nil
+	})
+	notFound := 0
+	router.NotFound = func(c tele.Context) error {
+		notFound++
+		return nil
+	}
+
+	bot, err := tele.NewBot(tele.Settings{Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := func(data string) tele.Context {
+		return bot.NewContext(tele.Update{Callback: &tele.Callback{Data: data}})
+	}
+
+	data, _ := codec.Encode("vote", vote{PostID: 42, Up: true})
+	if err := router.Handler()(callback(data)); err != nil {
+		t.Fatal(err)
+	}
+	if got.PostID != 42 || !got.Up {
+		t.Errorf("handler got %+v", got)
+	}
+
+	data, _ = codec.Encode("unknown", nil)
+	router.Handler()(callback(data))
+	router.Handler()(callback("vote|1|AAAAAAAA"))
+	if notFound != 2 {
+		t.Errorf("NotFound called %d times, want 2", notFound)
+	}
+}
@@ -0,0 +1,64 @@
+package keyboard
+
+import (
+	"errors"
+
+	"github.com/labstack/gommon/log"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Router dispatches callbacks by action to typed handlers
+//
+//	router := keyboard.NewRouter(keyboard.Default())
+//	keyboard.Handle(router, "vote", func(c tele.Context, vote Vote) error { ... })
+//	bot.Handle(tele.OnCallback, router.Handler())
+type Router struct {
+	codec    *Codec
+	handlers map[string]func(c tele.Context, cb *Callback) error
+	// NotFound handle callbacks of unknown action or bad signature, default answers the callback
+	NotFound tele.HandlerFunc
+}
+
+// NewRouter create a router decoding with codec
+func NewRouter(codec *Codec) *Router {
+	return &Router{
+		codec:    codec,
+		handlers: map[string]func(c tele.Context, cb *Callback) error{},
+		NotFound: func(c tele.Context) error {
+			return c.Respond()
+		},
+	}
+}
+
+// Handle register a typed handler for action
+func Handle[T any](router *Router, action string, fn func(c tele.Context, payload T) error) {
+	router.handlers[action] = func(c tele.Context, cb *Callback) error {
+		var payload T
+		if err := cb.Bind(&payload); err != nil {
+			return err
+		}
+		return fn(c, payload)
+	}
+}
+
+// Handler for tele.OnCallback
+func (router *Router) Handler() tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if c.Callback() == nil {
+			return nil
+		}
+		cb, err := router.codec.Decode(c.Callback().Data)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidSignature) && !errors.Is(err, ErrExpired) {
+				return err
+			}
+			log.Warnf("keyboard: callback %q: %v", c.Callback().Data, err)
+			return router.NotFound(c)
+		}
+		handler, ok := router.handlers[cb.Action]
+		if !ok {
+			return router.NotFound(c)
+		}
+		return handler(c, cb)
+	}
+}
@@ -0,0 +1,336 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/helper"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	tele "gopkg.in/telebot.v4"
+)
+
+// Record is a recorded update
+type Record struct {
+	Time   time.Time       `json:"time"`
+	BotID  int64           `json:"bot_id"`
+	ChatID int64           `json:"chat_id,omitempty"`
+	UserID int64           `json:"user_id,omitempty"`
+	Update json.RawMessage `json:"update"`
+}
+
+// mongoRecord is Record stored in mongo, update is kept as JSON text
+type mongoRecord struct {
+	Time   time.Time `bson:"time"`
+	BotID  int64     `bson:"bot_id"`
+	ChatID int64     `bson:"chat_id,omitempty"`
+	UserID int64     `bson:"user_id,omitempty"`
+	Update string    `bson:"update"`
+}
+
+// Filter selects records, zero fields match all
+type Filter struct {
+	BotID  int64
+	ChatID int64
+	UserID int64
+	From   time.Time
+	To     time.Time
+}
+
+// Match check if the record is selected
+func (f Filter) Match(record Record) bool {
+	if f.BotID != 0 && record.BotID != f.BotID {
+		return false
+	}
+	if f.ChatID != 0 && record.ChatID != f.ChatID {
+		return false
+	}
+	if f.UserID != 0 && record.UserID != f.UserID {
+		return false
+	}
+	if !f.From.IsZero() && record.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && record.Time.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// Writer saves records
+type Writer interface {
+	Write(record Record) error
+	Close() error
+}
+
+// NewRecord build a record of the update, tokens in it are masked
+func NewRecord(botID int64, update tele.Update) (Record, error) {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{
+		Time:   time.Now(),
+		BotID:  botID,
+		Update: MaskTokens(data),
+	}
+	switch {
+	case update.Message != nil:
+		if update.Message.Chat != nil {
+			record.ChatID = update.Message.Chat.ID
+		}
+		if update.Message.Sender != nil {
+			record.UserID = update.Message.Sender.ID
+		}
+	case update.Callback != nil:
+		if update.Callback.Message != nil && update.Callback.Message.Chat != nil {
+			record.ChatID = update.Callback.Message.Chat.ID
+		}
+		if update.Callback.Sender != nil {
+			record.UserID = update.Callback.Sender.ID
+		}
+	}
+	return record, nil
+}
+
+var tokenRegex = regexp.MustCompile(`(\d{5,15}):[\w-]{35}`)
+
+// MaskTokens hide bot tokens, e.g. a token pasted from BotFather
+func MaskTokens(data []byte) []byte {
+	return tokenRegex.ReplaceAll(data, []byte("$1:***********************************"))
+}
+
+// Middleware record every update before handling it
+func Middleware(w Writer) tele.MiddlewareFunc {
+	return func(next tele.HandlerFunc) tele.HandlerFunc {
+		return func(c tele.Context) error {
+			var botID int64
+			if bot, ok := c.Bot().(*tele.Bot); ok {
+				botID = helper.GetBotID(bot.Token)
+			}
+			record, err := NewRecord(botID, c.Update())
+			if err == nil {
+				err = w.Write(record)
+			}
+			if err != nil {
+				log.Errorf("recorder: update %d: %v", c.Update().ID, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// JSONLWriter appends records to a JSON lines file
+type JSONLWriter struct {
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewJSONLWriter open the file for appending
+func NewJSONLWriter(path string) (*JSONLWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLWriter{file: file}, nil
+}
+
+// Write a record as one line
+func (w *JSONLWriter) Write(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.file.Write(append(data, '\n'))
+	return err
+}
+
+// Close the file
+func (w *JSONLWriter) Close() error {
+	return w.file.Close()
+}
+
+// MongoWriter inserts records into a collection
+type MongoWriter struct {
+	collection string
+}
+
+// NewMongoWriter write to collection
+func NewMongoWriter(collection string) *MongoWriter {
+	return &MongoWriter{collection: collection}
+}
+
+// Write a record as one document
+func (w *MongoWriter) Write(record Record) error {
+	_, err := mongo.C(w.collection).Insert(mongoRecord{
+		Time:   record.Time,
+		BotID:  record.BotID,
+		ChatID: record.ChatID,
+		UserID: record.UserID,
+		Update: string(record.Update),
+	})
+	return err
+}
+
+// Close does nothing, the mongo client is shared
+func (w *MongoWriter) Close() error {
+	return nil
+}
+
+// ReadJSONL read records of a JSON lines file
+func ReadJSONL(path string, filter Filter) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, err
+		}
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sortRecords(records)
+	return records, nil
+}
+
+// ReadMongo read records of a collection
+func ReadMongo(collection string, filter Filter) ([]Record, error) {
+	query := bson.D{}
+	if filter.BotID != 0 {
+		query = append(query, bson.E{Key: "bot_id", Value: filter.BotID})
+	}
+	if filter.ChatID != 0 {
+		query = append(query, bson.E{Key: "chat_id", Value: filter.ChatID})
+	}
+	if filter.UserID != 0 {
+		query = append(query, bson.E{Key: "user_id", Value: filter.UserID})
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		between := bson.M{}
+		if !filter.From.IsZero() {
+			between["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			between["$lte"] = filter.To
+		}
+		query = append(query, bson.E{Key: "time", Value: between})
+	}
+
+	var docs []mongoRecord
+	if err := mongo.C(collection).Where(query).SortAsc("time").Find(&docs); err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(docs))
+	for _, doc := range docs {
+		records = append(records, Record{
+			Time:   doc.Time,
+			BotID:  doc.BotID,
+			ChatID: doc.ChatID,
+			UserID: doc.UserID,
+			Update: json.RawMessage(doc.Update),
+		})
+	}
+	sortRecords(records)
+	return records, nil
+}
+
+// sortRecords by time, stable for records of the same time
+func sortRecords(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+}
+
+// ReplayOptions controls the replay speed
+type ReplayOptions struct {
+	// Speed compresses the time between records, 10 means 10 times faster, zero means no waiting
+	Speed float64
+	// MaxGap caps the wait between two records after compression, zero means no cap
+	MaxGap time.Duration
+}
+
+// Replay feed records in order to fn, waiting between them as recorded
+func Replay(records []Record, options ReplayOptions, fn func(update tele.Update) error) error {
+	for i, record := range records {
+		if i > 0 && options.Speed > 0 {
+			wait := time.Duration(float64(record.Time.Sub(records[i-1].Time)) / options.Speed)
+			if options.MaxGap > 0 && wait > options.MaxGap {
+				wait = options.MaxGap
+			}
+			if wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		var update tele.Update
+		if err := json.Unmarshal(record.Update, &update); err != nil {
+			return err
+		}
+		if err := fn(update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToBot process updates with the handlers of bot, create it with Synchronous for a deterministic replay
+func ToBot(bot *tele.Bot) func(update tele.Update) error {
+	return func(update tele.Update) error {
+		bot.ProcessUpdate(update)
+		return nil
+	}
+}
+
+// ToWebhook post updates to a webhook, e.g. the local dev server
+func ToWebhook(url, secret string) func(update tele.Update) error {
+	client := &http.Client{Timeout: time.Minute}
+	return func(update tele.Update) error {
+		body, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return errors.New("recorder: webhook responded " + resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package recorder
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v4"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "updates.jsonl")
+	writer, err := NewJSONLWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot, err := tele.NewBot(tele.Settings{Token: "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1", Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot.Use(Middleware(writer))
+	bot.Handle(tele.OnText, func(c tele.Context) error { return nil })
+
+	texts := []string{"/start", "my token is 987654321:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw1", "done"}
+	for i, text := range texts {
+		bot.ProcessUpdate(tele.Update{ID: i + 1, Message: &tele.Message{
+			ID:     i + 1,
+			Text:   text,
+			Chat:   &tele.Chat{ID: 42},
+			Sender: &tele.User{ID: 7},
+		}})
+	}
+	writer.Close()
+
+	records, err := ReadJSONL(path, Filter{ChatID: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("ReadJSONL() = %d records, want 3", len(records))
+	}
+	if records[0].BotID != 123456789 || records[0].UserID != 7 {
+		t.Errorf("record = %+v", records[0])
+	}
+	if strings.Contains(string(records[1].Update), "AAHdq") {
+		t.Errorf("token is not masked: %s", records[1].Update)
+	}
+	if other, _ := ReadJSONL(path, Filter{ChatID: 1}); len(other) != 0 {
+		t.Errorf("Filter{ChatID: 1} matched %d records", len(other))
+	}
+
+	// 记录间隔 1 秒，10 倍速回放约 100ms
+	records[1].Time = records[0].Time.Add(time.Second)
+	records[2].Time = records[1].Time.Add(time.Hour)
+	replayed := []string{}
+	start := time.Now()
+	err = Replay(records, ReplayOptions{Speed: 10, MaxGap: 50 * time.Millisecond}, func(update tele.Update) error {
+		replayed = append(replayed, update.Message.Text)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Replay() took %v", elapsed)
+	}
+	if len(replayed) != 3 || replayed[0] != "/start" || replayed[2] != "done" {
+		t.Errorf("Replay() = %v", replayed)
+	}
+}
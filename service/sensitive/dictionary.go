@@ -0,0 +1,232 @@
+package sensitive
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Category why a word is sensitive, dictionaries may use their own
+type Category string
+
+// Categories of sensitive words
+const (
+	CategoryNone     Category = ""
+	CategoryPolitics Category = "politics"
+	CategoryPorn     Category = "porn"
+	CategoryAds      Category = "ads"
+	CategoryAbuse    Category = "abuse"
+)
+
+// Severity what to do with a text containing the word
+type Severity int
+
+// Severities, in increasing order
+const (
+	SeverityAllow  Severity = iota // 只记录，不处理
+	SeverityReview                 // 需要人工审核
+	SeverityBlock                  // 直接拦截
+)
+
+var severityNames = map[Severity]string{
+	SeverityAllow:  "allow",
+	SeverityReview: "review",
+	SeverityBlock:  "block",
+}
+
+// String is the name used in dictionaries
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity parse allow, review or block
+func ParseSeverity(name string) (Severity, error) {
+	for severity, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return severity, nil
+		}
+	}
+	return SeverityBlock, fmt.Errorf("unknown severity: %s", name)
+}
+
+// Entry a word of the dictionary with its metadata
+type Entry struct {
+	Word     string
+	Category Category
+	Severity Severity
+}
+
+// ParseEntry parse a dictionary line "word[\tcategory[\tseverity]]", the
+// severity defaults to block, so plain word lists keep working
+func ParseEntry(line string) Entry {
+	fields := strings.Split(line, "\t")
+	entry := Entry{Word: strings.TrimSpace(fields[0]), Severity: SeverityBlock}
+	if len(fields) > 1 {
+		entry.Category = Category(strings.ToLower(strings.TrimSpace(fields[1])))
+	}
+	if len(fields) > 2 {
+		if severity, err := ParseSeverity(strings.TrimSpace(fields[2])); err == nil {
+			entry.Severity = severity
+		}
+	}
+	return entry
+}
+
+// String format the entry as a dictionary line
+func (e Entry) String() string {
+	if e.Category == CategoryNone && e.Severity == SeverityBlock {
+		return e.Word
+	}
+	return e.Word + "\t" + string(e.Category) + "\t" + e.Severity.String()
+}
+
+// Match a dictionary word found in a text, offsets are in runes
+type Match struct {
+	Entry
+	Text  string // 原文中匹配的文本
+	Start int
+	End   int // 不包含
+}
+
+// Action what Replace does with the matches of a category
+type Action int
+
+// Actions of Replace
+const (
+	ActionMask   Action = iota // 替换为指定字符
+	ActionRemove               // 删除
+	ActionKeep                 // 保留
+)
+
+// WithPolicy set what Replace does with the matches of a category, matches
+// are masked by default and words of severity allow are always kept
+func WithPolicy(category Category, action Action) Option {
+	return func(sf *SensitiveFilter) {
+		sf.policies[category] = action
+	}
+}
+
+// dictNode a node of the dictionary trie
+type dictNode struct {
+	children map[rune]*dictNode
+	entry    *dictEntry // 以此结尾的词
+}
+
+// dictEntry an entry and how its key was made
+type dictEntry struct {
+	Entry
+	spelled bool // 由拼音生成，只在单词边界匹配
+}
+
+// dictionary a trie of entries, read only once built, see with
+type dictionary struct {
+	root    dictNode
+	longest int // 最长词的字符数
+}
+
+func newDictionary() *dictionary {
+	return &dictionary{root: dictNode{children: map[rune]*dictNode{}}}
+}
+
+// add insert or replace the entry of key, key is the word or a normalized
+// form of it, spelled when it is the pinyin of the word
+func (d *dictionary) add(key string, entry Entry, spelled bool) {
+	runes := []rune(key)
+	if len(runes) == 0 {
+		return
+	}
+	node := &d.root
+	for _, r := range runes {
+		next, ok := node.children[r]
+		if !ok {
+			next = &dictNode{children: map[rune]*dictNode{}}
+			node.children[r] = next
+		}
+		node = next
+	}
+	node.entry = &dictEntry{Entry: entry, spelled: spelled}
+	d.longest = max(d.longest, len(runes))
+}
+
+// get the entry of key, nil when there is none
+func (d *dictionary) get(key string) *dictEntry {
+	node := &d.root
+	for _, r := range key {
+		if node = node.children[r]; node == nil {
+			return nil
+		}
+	}
+	return node.entry
+}
+
+// with return a copy of the dictionary with the entries of keys replaced, a
+// nil entry removes the key. Only the nodes on the paths of keys are copied,
+// so d can still be read meanwhile.
+func (d *dictionary) with(changes map[string]*dictEntry) *dictionary {
+	out := &dictionary{root: dictNode{children: maps.Clone(d.root.children), entry: d.root.entry}, longest: d.longest}
+	copied := map[*dictNode]bool{&out.root: true}
+	for key, entry := range changes {
+		runes := []rune(key)
+		if len(runes) == 0 {
+			continue
+		}
+		node := &out.root
+		for _, r := range runes {
+			next := node.children[r]
+			switch {
+			case next == nil && entry == nil:
+				// 要删除的词不存在
+				node = nil
+			case next == nil:
+				next = &dictNode{children: map[rune]*dictNode{}}
+				copied[next] = true
+			case !copied[next]:
+				next = &dictNode{children: maps.Clone(next.children), entry: next.entry}
+				copied[next] = true
+			}
+			if node == nil {
+				break
+			}
+			node.children[r] = next
+			node = next
+		}
+		if node != nil {
+			node.entry = entry
+		}
+		if entry != nil {
+			out.longest = max(out.longest, len(runes))
+		}
+	}
+	return out
+}
+
+// match the longest entry starting at runes[start], end is exclusive. Spelled
+// entries only match when boundary returns true, or boundary is nil
+func (d *dictionary) match(runes []rune, start int, boundary func(start, end int) bool) (*Entry, int) {
+	var entry *Entry
+	end := start
+	node := &d.root
+	for i := start; i < len(runes); i++ {
+		if node = node.children[runes[i]]; node == nil {
+			break
+		}
+		if node.entry != nil && (!node.entry.spelled || boundary == nil || boundary(start, i+1)) {
+			entry, end = &node.entry.Entry, i+1
+		}
+	}
+	return entry, end
+}
+
+// scan find the longest entry at every position, matches may overlap
+func (d *dictionary) scan(runes []rune, boundary func(start, end int) bool) []Match {
+	var matches []Match
+	for start := range runes {
+		if entry, end := d.match(runes, start, boundary); entry != nil {
+			matches = append(matches, Match{Entry: *entry, Text: string(runes[start:end]), Start: start, End: end})
+		}
+	}
+	return matches
+}
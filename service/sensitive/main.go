@@ -4,16 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"regexp"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"net/http/httputil"
 	"strings"
 
 	"github.com/labstack/gommon/log"
 	"github.com/mylukin/sensitive"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
@@ -22,18 +24,48 @@ var (
 
 type SensitiveFilter struct {
 	filter         atomic.Value // 存储 *sensitive.Filter
+	filterMu       sync.RWMutex // 运行时增删词时直接修改 filter 的字典树
+	dict           atomic.Pointer[dictionary]
 	dictURL        string
 	updateInterval time.Duration
 	client         *http.Client
 	ctx            context.Context
 	cancel         context.CancelFunc
-	lastModified   string
-	logMaxLines    int  // 日志输出的最大行数
-	debug          bool // 新增：调试模式标志
+	sources        []*sourceState
+	mu             sync.Mutex // 保护 sources 的词典、noisePattern 和运行时增删的词
+	noisePattern   string
+	noise          atomic.Pointer[regexp.Regexp] // Scan 之前去掉的噪音，未设置时不去掉
+	base           map[string]Entry              // 所有来源合并后的词
+	added          map[string]string             // AddWord 添加的词 => 词典行
+	removed        map[string]bool               // DelWord 删除的词
+	pending        map[string]string             // 保存失败的修改，词 => 词典行，删除的词为空
+	overlayStore   OverlayStore
+	id             string // 副本标识，忽略自己保存的修改通知
+	policies       map[Category]Action
+	normalizer     normalizer
+	allowlist      []string
+	allow          *dictionary // 白名单，为空时不检查
+	logMaxLines    int         // 日志输出的最大行数
+	debug          bool        // 新增：调试模式标志
+}
+
+// sourceState a source with the last dictionary it returned successfully
+type sourceState struct {
+	source   Source
+	interval time.Duration
+	lines    []string
 }
 
 type Option func(*SensitiveFilter)
 
+// WithSource add a dictionary source refreshed every interval, interval <= 0
+// uses the update interval. The words of all sources are combined.
+func WithSource(source Source, interval time.Duration) Option {
+	return func(sf *SensitiveFilter) {
+		sf.sources = append(sf.sources, &sourceState{source: source, interval: interval})
+	}
+}
+
 func WithUpdateInterval(interval time.Duration) Option {
 	return func(sf *SensitiveFilter) {
 		sf.updateInterval = interval
@@ -58,11 +90,9 @@ func WithDebugMode(debug bool) Option {
 	}
 }
 
+// New create a filter of the dictionary at dictURL and the sources added with
+// WithSource, dictURL may be empty when there are other sources
 func New(dictURL string, options ...Option) (*SensitiveFilter, error) {
-	if dictURL == "" {
-		return nil, errors.New("dictURL is empty")
-	}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	sf := &SensitiveFilter{
 		dictURL:        dictURL,
@@ -72,26 +102,72 @@ func New(dictURL string, options ...Option) (*SensitiveFilter, error) {
 		cancel:         cancel,
 		logMaxLines:    50,    // 默认值设为 50
 		debug:          false, // 默认关闭调试模式
+		id:             primitive.NewObjectID().Hex(),
+		added:          map[string]string{},
+		removed:        map[string]bool{},
+		pending:        map[string]string{},
+		policies:       map[Category]Action{},
 	}
 
 	for _, option := range options {
 		option(sf)
 	}
 
-	if err := sf.updateDict(); err != nil {
-		log.Warnf("Failed to initialize dictionary from URL, using empty filter: %v", err)
-		// 不返回错误，继续使用空过滤器
-		// 初始化一个空的过滤器作为默认值
-		defaultFilter := sensitive.New()
-		sf.filter.Store(defaultFilter)
+	if dictURL != "" {
+		source := NewHTTPSource(dictURL, sf.client)
+		if sf.debug {
+			source.dump = sf.logHTTP
+		}
+		sf.sources = append([]*sourceState{{source: source}}, sf.sources...)
+	}
+	if len(sf.sources) == 0 {
+		cancel()
+		return nil, errors.New("dictURL is empty and there is no other source")
 	}
 
-	go sf.autoUpdate()
+	if len(sf.allowlist) > 0 {
+		sf.allow = newDictionary()
+		for _, word := range sf.allowlist {
+			sf.allow.add(sf.normalizer.normalize(word), Entry{Word: word, Severity: SeverityAllow}, false)
+		}
+	}
+
+	loaded := 0
+	for _, state := range sf.sources {
+		if state.interval <= 0 {
+			state.interval = sf.updateInterval
+		}
+		if _, err := sf.refresh(state); err != nil {
+			log.Warnf("Failed to initialize dictionary from %s: %v", state.source.Name(), err)
+			continue
+		}
+		loaded++
+	}
+	if loaded == 0 {
+		// 不返回错误，继续使用空过滤器，等待下次更新
+		log.Warn("No dictionary loaded, using empty filter")
+	}
+	if sf.overlayStore != nil {
+		if err := sf.loadOverlay(false); err != nil {
+			log.Warnf("Failed to load sensitive words: %v", err)
+		}
+		go sf.watchOverlay()
+	}
+	sf.rebuild()
+
+	for _, state := range sf.sources {
+		go sf.autoUpdate(state)
+	}
 
 	return sf, nil
 }
 
+// Get the shared filter of dictURL, created with options on the first call.
+// Instances are cached by dictURL only, use New for filters of other sources
 func Get(dictURL string, options ...Option) (*SensitiveFilter, error) {
+	if dictURL == "" {
+		return nil, errors.New("dictURL is empty, use New for filters without a URL")
+	}
 	// 尝试从 sync.Map 中获取实例
 	if instance, ok := instances.Load(dictURL); ok {
 		return instance.(*SensitiveFilter), nil
@@ -115,147 +191,142 @@ func Get(dictURL string, options ...Option) (*SensitiveFilter, error) {
 	return instance, nil
 }
 
-func (sf *SensitiveFilter) checkUpdate() (bool, error) {
-	req, err := http.NewRequestWithContext(sf.ctx, http.MethodHead, sf.dictURL, nil)
-	if err != nil {
-		return false, fmt.Errorf("failed to create HEAD request: %v", err)
+// refresh fetch a source, its last good dictionary is kept when it fails
+func (sf *SensitiveFilter) refresh(state *sourceState) (bool, error) {
+	lines, changed, err := state.source.Fetch(sf.ctx)
+	if err != nil || !changed {
+		return false, err
 	}
 
-	if sf.lastModified != "" {
-		req.Header.Set("If-Modified-Since", sf.lastModified)
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if len(lines) == 0 && len(state.lines) > 0 {
+		return false, errors.New("dictionary is empty")
 	}
+	state.lines = lines
+	return true, nil
+}
 
-	// 确保请求方法是 HEAD
-	req.Method = http.MethodHead
-
-	// Log HTTP request before sending
-	if sf.debug {
-		// 仅在调试模式下执行
-		reqDump, err := httputil.DumpRequestOut(req, true)
-		if err != nil {
-			log.Errorf("Failed to dump request: %v", err)
-		} else {
-			sf.logHTTP(reqDump, true)
+// rebuild build a new filter of the words of all sources, then apply the
+// words added and removed at runtime. Only needed when a source changed,
+// runtime changes are applied with apply.
+func (sf *SensitiveFilter) rebuild() {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	sf.base = map[string]Entry{}
+	for _, state := range sf.sources {
+		for _, line := range state.lines {
+			entry := ParseEntry(line)
+			sf.base[entry.Word] = entry
 		}
 	}
-
-	resp, err := sf.client.Do(req)
-	if err != nil {
-		return false, fmt.Errorf("failed to send HEAD request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Log HTTP response (limited to 50 lines)
-	if sf.debug {
-		// 仅在调试模式下执行
-		respDump, err := httputil.DumpResponse(resp, false)
-		if err != nil {
-			log.Errorf("Failed to dump response: %v", err)
-		} else {
-			sf.logHTTP(respDump, false)
-		}
+	entries := maps.Clone(sf.base)
+	for word, line := range sf.added {
+		entries[word] = ParseEntry(line)
 	}
-
-	if resp.StatusCode == http.StatusNotModified {
-		return false, nil
+	for word := range sf.removed {
+		delete(entries, word)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return false, fmt.Errorf("HEAD request failed, status code: %d", resp.StatusCode)
+	dict := newDictionary()
+	newFilter := sensitive.New()
+	if sf.noisePattern != "" {
+		newFilter.UpdateNoisePattern(sf.noisePattern)
 	}
-
-	newLastModified := resp.Header.Get("Last-Modified")
-	if newLastModified != "" && newLastModified != sf.lastModified {
-		sf.lastModified = newLastModified
-		return true, nil
+	for _, entry := range entries {
+		for key, spelled := range sf.normalizer.keys(entry.Word) {
+			dict.add(key, entry, spelled)
+		}
+		// 允许的词只在 Scan 中报告
+		if entry.Severity != SeverityAllow {
+			newFilter.AddWord(entry.Word)
+		}
 	}
-
-	return false, nil
+	sf.filter.Store(newFilter)
+	sf.dict.Store(dict)
 }
 
-func (sf *SensitiveFilter) updateDict() error {
-	req, err := http.NewRequestWithContext(sf.ctx, http.MethodGet, sf.dictURL, nil)
-	if err != nil {
-		log.Errorf("Failed to create request: %v", err)
-		return err
+// entry the entry of word once the runtime changes are applied, nil when
+// it is not in the dictionary. sf.mu must be held.
+func (sf *SensitiveFilter) entry(word string) *Entry {
+	if line, ok := sf.added[word]; ok {
+		entry := ParseEntry(line)
+		return &entry
 	}
-
-	if sf.lastModified != "" {
-		req.Header.Set("If-Modified-Since", sf.lastModified)
+	if entry, ok := sf.base[word]; ok && !sf.removed[word] {
+		return &entry
 	}
+	return nil
+}
 
-	// Log HTTP request
-	if sf.debug {
-		// 仅在调试模式下执行
-		reqDump, err := httputil.DumpRequestOut(req, true)
-		if err != nil {
-			log.Errorf("Failed to dump request: %v", err)
-		} else {
-			sf.logHTTP(reqDump, true)
-		}
+// apply update the dictionary and the filter for the words of before, which
+// holds their entries before the runtime changes. Unlike rebuild it only
+// touches these words. sf.mu must be held.
+func (sf *SensitiveFilter) apply(before map[string]*Entry) {
+	dict := sf.dict.Load()
+	filter := sf.getFilter()
+	if dict == nil || filter == nil {
+		return
 	}
-
-	resp, err := sf.client.Do(req)
-	if err != nil {
-		log.Errorf("Failed to send request: %v", err)
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Log HTTP response (limited to 50 lines)
-	if sf.debug {
-		// 仅在调试模式下执行
-		respDump, err := httputil.DumpResponse(resp, true)
-		if err != nil {
-			log.Errorf("Failed to dump response: %v", err)
-		} else {
-			sf.logHTTP(respDump, false)
+	changes := map[string]*dictEntry{}
+	added := map[string]*Entry{}
+	sf.filterMu.Lock()
+	for word, old := range before {
+		entry := sf.entry(word)
+		if entry != nil && old != nil && *entry == *old {
+			continue
+		}
+		if old != nil {
+			for key := range sf.normalizer.keys(word) {
+				// 不同的词归一化后可能相同
+				if e := dict.get(key); e != nil && e.Word == word {
+					changes[key] = nil
+				}
+			}
+			if old.Severity != SeverityAllow {
+				filter.DelWord(word)
+			}
+		}
+		if entry != nil {
+			added[word] = entry
 		}
 	}
-
-	if resp.StatusCode == http.StatusNotModified {
-		log.Info("Dictionary not modified, no update needed")
-		return nil
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		log.Errorf("Failed to fetch dictionary, status code: %d", resp.StatusCode)
-		return errors.New("failed to fetch dictionary")
-	}
-
-	newLastModified := resp.Header.Get("Last-Modified")
-	if newLastModified != "" {
-		sf.lastModified = newLastModified
+	for word, entry := range added {
+		for key, spelled := range sf.normalizer.keys(word) {
+			changes[key] = &dictEntry{Entry: *entry, spelled: spelled}
+		}
+		if entry.Severity != SeverityAllow {
+			filter.AddWord(word)
+		}
 	}
-
-	newFilter := sensitive.New()
-	if err := newFilter.Load(resp.Body); err != nil {
-		log.Errorf("Failed to load new dictionary: %v", err)
-		return err
+	sf.filterMu.Unlock()
+	if len(changes) > 0 {
+		sf.dict.Store(dict.with(changes))
 	}
-
-	sf.filter.Store(newFilter)
-
-	log.Info("Dictionary updated successfully")
-	return nil
 }
 
-func (sf *SensitiveFilter) autoUpdate() {
-	ticker := time.NewTicker(sf.updateInterval)
+func (sf *SensitiveFilter) autoUpdate(state *sourceState) {
+	ticker := time.NewTicker(state.interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			needUpdate, err := sf.checkUpdate()
+			changed, err := sf.refresh(state)
 			if err != nil {
-				log.Errorf("Failed to check for updates: %v %s", err, sf.dictURL)
+				log.Errorf("Failed to update dictionary from %s: %v", state.source.Name(), err)
 				continue
 			}
-			if needUpdate {
-				if err := sf.updateDict(); err != nil {
-					log.Errorf("Failed to update dictionary: %v", err)
+			if changed {
+				if sf.overlayStore != nil {
+					// 顺便同步其他副本的修改，以防错过通知
+					if err := sf.loadOverlay(false); err != nil {
+						log.Errorf("Failed to load sensitive words: %v", err)
+					}
 				}
+				sf.rebuild()
+				log.Infof("Dictionary updated successfully from %s", state.source.Name())
 			}
 		case <-sf.ctx.Done():
 			return
@@ -280,29 +351,104 @@ func (sf *SensitiveFilter) getFilter() *sensitive.Filter {
 	return filter.(*sensitive.Filter)
 }
 
+// AddWord add words at runtime, they are kept across dictionary reloads. A
+// word may be a dictionary line with a category and severity, see ParseEntry
 func (sf *SensitiveFilter) AddWord(words ...string) {
-	if len(words) == 0 {
+	if len(words) == 0 || sf.getFilter() == nil {
 		return
 	}
-	filter := sf.getFilter()
-	if filter == nil {
-		return
-	}
-	filter.AddWord(words...)
+	sf.setOverlay(words, true)
+	sf.saveOverlay(words, true)
 }
 
+// DelWord remove words at runtime, they stay removed across dictionary reloads
 func (sf *SensitiveFilter) DelWord(words ...string) {
-	if len(words) == 0 {
+	if len(words) == 0 || sf.getFilter() == nil {
 		return
 	}
-	filter := sf.getFilter()
-	if filter == nil {
-		return
+	sf.setOverlay(words, false)
+	sf.saveOverlay(words, false)
+}
+
+// Scan find the dictionary words in text with their rune offsets, category
+// and severity, in order of position; matches may overlap
+func (sf *SensitiveFilter) Scan(text string) []Match {
+	dict := sf.dict.Load()
+	if dict == nil {
+		return nil
+	}
+	return sf.scan(dict, []rune(text))
+}
+
+// normalizing whether matching goes through the normalizer
+func (sf *SensitiveFilter) normalizing() bool {
+	return sf.normalizer.enabled() || sf.normalizer.pinyin != nil || sf.allow != nil
+}
+
+func (sf *SensitiveFilter) scan(dict *dictionary, runes []rune) []Match {
+	kept, index := sf.removeNoise(runes)
+	var matches []Match
+	if !sf.normalizing() {
+		matches = dict.scan(kept, nil)
+	} else {
+		matches = sf.normalizer.scan(dict, sf.allow, kept)
+	}
+	if index != nil {
+		// 映射回原文的位置
+		for i, m := range matches {
+			m.Start, m.End = index[m.Start], index[m.End-1]+1
+			m.Text = string(runes[m.Start:m.End])
+			matches[i] = m
+		}
+	}
+	return matches
+}
+
+// removeNoise remove the text matching the noise pattern, index is the
+// position in runes of each kept rune, nil when nothing was removed
+func (sf *SensitiveFilter) removeNoise(runes []rune) (kept []rune, index []int) {
+	noise := sf.noise.Load()
+	if noise == nil {
+		return runes, nil
+	}
+	text := string(runes)
+	locs := noise.FindAllStringIndex(text, -1)
+	if len(locs) == 0 {
+		return runes, nil
+	}
+	kept = make([]rune, 0, len(runes))
+	index = make([]int, 0, len(runes))
+	i := 0
+	for offset, r := range text {
+		for len(locs) > 0 && locs[0][1] <= offset {
+			locs = locs[1:]
+		}
+		if len(locs) == 0 || offset < locs[0][0] {
+			kept = append(kept, r)
+			index = append(index, i)
+		}
+		i++
+	}
+	return kept, index
+}
+
+// Moderate scan text and return the highest severity of the matches,
+// SeverityAllow when nothing matches
+func (sf *SensitiveFilter) Moderate(text string) (Severity, []Match) {
+	matches := sf.Scan(text)
+	severity := SeverityAllow
+	for _, match := range matches {
+		severity = max(severity, match.Severity)
 	}
-	filter.DelWord(words...)
+	return severity, matches
 }
 
 func (sf *SensitiveFilter) Filter(text string) string {
+	if sf.normalizing() {
+		return sf.replace(text, 0, func(Match) Action { return ActionRemove })
+	}
+	sf.filterMu.RLock()
+	defer sf.filterMu.RUnlock()
 	filter := sf.getFilter()
 	if filter == nil {
 		return text
@@ -310,15 +456,62 @@ func (sf *SensitiveFilter) Filter(text string) string {
 	return filter.Filter(text)
 }
 
+// Replace mask the sensitive words with repl, or apply the policy of their
+// category, see WithPolicy
 func (sf *SensitiveFilter) Replace(text string, repl rune) string {
-	filter := sf.getFilter()
-	if filter == nil {
+	return sf.replace(text, repl, func(match Match) Action {
+		return sf.policies[match.Category]
+	})
+}
+
+// replace apply the action returned by policy to each match
+func (sf *SensitiveFilter) replace(text string, repl rune, policy func(Match) Action) string {
+	dict := sf.dict.Load()
+	if dict == nil {
+		return text
+	}
+	runes := []rune(text)
+	matches := sf.scan(dict, runes)
+	if len(matches) == 0 {
 		return text
 	}
-	return filter.Replace(text, repl)
+
+	// 每个字符的处理方式，重叠时删除优先于替换
+	actions := make([]Action, len(runes))
+	for i := range actions {
+		actions[i] = ActionKeep
+	}
+	for _, match := range matches {
+		action := policy(match)
+		if match.Severity == SeverityAllow || action == ActionKeep {
+			continue
+		}
+		for i := match.Start; i < match.End; i++ {
+			if actions[i] != ActionRemove {
+				actions[i] = action
+			}
+		}
+	}
+
+	result := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch actions[i] {
+		case ActionMask:
+			result = append(result, repl)
+		case ActionKeep:
+			result = append(result, r)
+		}
+	}
+	return string(result)
 }
 
 func (sf *SensitiveFilter) FindIn(text string) (bool, string) {
+	if sf.normalizing() {
+		valid, word := sf.Validate(text)
+		return !valid, word
+	}
+	sf.filterMu.RLock()
+	defer sf.filterMu.RUnlock()
 	filter := sf.getFilter()
 	if filter == nil {
 		return false, ""
@@ -327,6 +520,16 @@ func (sf *SensitiveFilter) FindIn(text string) (bool, string) {
 }
 
 func (sf *SensitiveFilter) Validate(text string) (bool, string) {
+	if sf.normalizing() {
+		for _, match := range sf.Scan(text) {
+			if match.Severity != SeverityAllow {
+				return false, match.Word
+			}
+		}
+		return true, ""
+	}
+	sf.filterMu.RLock()
+	defer sf.filterMu.RUnlock()
 	filter := sf.getFilter()
 	if filter == nil {
 		return true, ""
@@ -335,6 +538,19 @@ func (sf *SensitiveFilter) Validate(text string) (bool, string) {
 }
 
 func (sf *SensitiveFilter) FindAll(text string) []string {
+	if sf.normalizing() {
+		words := []string{}
+		seen := map[string]bool{}
+		for _, match := range sf.Scan(text) {
+			if match.Severity != SeverityAllow && !seen[match.Word] {
+				seen[match.Word] = true
+				words = append(words, match.Word)
+			}
+		}
+		return words
+	}
+	sf.filterMu.RLock()
+	defer sf.filterMu.RUnlock()
 	filter := sf.getFilter()
 	if filter == nil {
 		return []string{}
@@ -342,11 +558,18 @@ func (sf *SensitiveFilter) FindAll(text string) []string {
 	return filter.FindAll(text)
 }
 
+// UpdateNoisePattern set the noise removed from the text before matching, such
+// as the * of 垃*圾; Scan, Moderate and Replace still report and replace the
+// original text, with the noise inside a match
 func (sf *SensitiveFilter) UpdateNoisePattern(pattern string) {
 	currentFilter := sf.getFilter()
 	if currentFilter == nil {
 		return
 	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.noisePattern = pattern // 重新加载词典后仍然有效
+	sf.noise.Store(regexp.MustCompile(pattern))
 	newFilter := sensitive.New()
 	*newFilter = *currentFilter // 复制所有字段
 	newFilter.UpdateNoisePattern(pattern)
@@ -354,6 +577,8 @@ func (sf *SensitiveFilter) UpdateNoisePattern(pattern string) {
 }
 
 func (sf *SensitiveFilter) Length() int64 {
+	sf.filterMu.RLock()
+	defer sf.filterMu.RUnlock()
 	filter := sf.getFilter()
 	if filter == nil {
 		return 0
@@ -1,9 +1,20 @@
 package sensitive
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
 	"time"
 )
 
@@ -113,3 +124,559 @@ func TestSensitiveFilter(t *testing.T) {
 
 	time.Sleep(10 * time.Minute)
 }
+
+func TestSources(t *testing.T) {
+	var fail atomic.Bool
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if fail.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "垃圾\n废物\n")
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(path, []byte("笨蛋\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	embedded := fstest.MapFS{"dict/words.txt": {Data: []byte("白痴\n")}}
+
+	filter, err := New(server.URL,
+		WithUpdateInterval(20*time.Millisecond),
+		WithSource(NewFileSource(path), 20*time.Millisecond),
+		WithSource(NewFSSource(embedded, "dict/words.txt"), time.Hour),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer filter.Close()
+
+	if got := filter.FindAll("垃圾 笨蛋 白痴 废物"); len(got) != 4 {
+		t.Errorf("FindAll() = %v, want words of all sources", got)
+	}
+
+	// 文件更新后重新加载
+	if err := os.WriteFile(path, []byte("笨蛋\n蠢货\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for found, _ := filter.FindIn("蠢货"); !found; found, _ = filter.FindIn("蠢货") {
+		if time.Now().After(deadline) {
+			t.Fatal("file source was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for notModified.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("HTTP source did not revalidate with ETag")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 获取失败时保留上次的词典
+	fail.Store(true)
+	os.Remove(path)
+	seen := requests.Load()
+	for requests.Load() < seen+2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if found, _ := filter.FindIn("垃圾"); !found {
+		t.Error("dictionary of a failing HTTP source was dropped")
+	}
+	if found, _ := filter.FindIn("蠢货"); !found {
+		t.Error("dictionary of a missing file was dropped")
+	}
+
+	if _, err := New(""); err == nil {
+		t.Error("New() without sources should fail")
+	}
+	if _, err := Get("", WithSource(NewFileSource(path), time.Hour)); err == nil {
+		t.Error("Get() without dictURL should fail, instances are cached by dictURL")
+	}
+}
+
+func TestFSSource(t *testing.T) {
+	source := NewFSSource(fstest.MapFS{"words.txt": {Data: []byte("垃圾\n\n 废物 \n")}}, "words.txt")
+	lines, changed, err := source.Fetch(context.Background())
+	if err != nil || !changed || len(lines) != 2 || lines[1] != "废物" {
+		t.Fatalf("Fetch() = %q, %v, %v", lines, changed, err)
+	}
+	if lines, changed, err = source.Fetch(context.Background()); err != nil || changed || lines != nil {
+		t.Errorf("second Fetch() = %q, %v, %v", lines, changed, err)
+	}
+	// 并发调用，失败后可以重试
+	fsys := fstest.MapFS{}
+	source = NewFSSource(fsys, "words.txt")
+	var wg sync.WaitGroup
+	var failed, loaded atomic.Int32
+	fetch := func() {
+		defer wg.Done()
+		_, changed, err := source.Fetch(context.Background())
+		if err != nil {
+			failed.Add(1)
+		}
+		if changed {
+			loaded.Add(1)
+		}
+	}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go fetch()
+	}
+	wg.Wait()
+	if failed.Load() != 8 {
+		t.Errorf("Fetch() of a missing file failed %d times, want 8", failed.Load())
+	}
+	fsys["words.txt"] = &fstest.MapFile{Data: []byte("垃圾\n")}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go fetch()
+	}
+	wg.Wait()
+	if loaded.Load() != 1 {
+		t.Errorf("concurrent Fetch() loaded %d times, want 1", loaded.Load())
+	}
+}
+
+// memoryOverlayStore an OverlayStore shared by the filters of a test
+type memoryOverlayStore struct {
+	mu       sync.Mutex
+	words    map[string]bool
+	watchers []chan string
+	loads    atomic.Int32
+	fail     atomic.Bool
+}
+
+func (s *memoryOverlayStore) Load(ctx context.Context) (Overlay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads.Add(1)
+	var overlay Overlay
+	for word, added := range s.words {
+		if added {
+			overlay.Added = append(overlay.Added, word)
+		} else {
+			overlay.Removed = append(overlay.Removed, word)
+		}
+	}
+	return overlay, nil
+}
+
+func (s *memoryOverlayStore) Save(ctx context.Context, origin string, words []string, added bool) error {
+	if s.fail.Load() {
+		return fmt.Errorf("store is down")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, word := range words {
+		s.words[word] = added
+	}
+	for _, watcher := range s.watchers {
+		select {
+		case watcher <- origin:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *memoryOverlayStore) Watch(ctx context.Context, onChange func(origin string)) error {
+	watcher := make(chan string, 16) // 通知不能合并，来源不同
+	s.mu.Lock()
+	s.watchers = append(s.watchers, watcher)
+	s.mu.Unlock()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case origin := <-watcher:
+			onChange(origin)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dict.txt")
+	if err := os.WriteFile(path, []byte("垃圾\n废物\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryOverlayStore{words: map[string]bool{}}
+	newFilter := func() *SensitiveFilter {
+		filter, err := New("", WithSource(NewFileSource(path), 20*time.Millisecond), WithOverlayStore(store))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		return filter
+	}
+	filter := newFilter()
+	defer filter.Close()
+	replica := newFilter()
+	defer replica.Close()
+
+	filter.AddWord("笨蛋")
+	filter.DelWord("废物")
+
+	// 词典重新加载后运行时的修改仍然有效
+	if err := os.WriteFile(path, []byte("垃圾\n废物\n蠢货\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for _, f := range []*SensitiveFilter{filter, replica} {
+		for found, _ := f.FindIn("蠢货"); !found; found, _ = f.FindIn("蠢货") {
+			if time.Now().After(deadline) {
+				t.Fatal("dictionary was not reloaded")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if found, _ := f.FindIn("笨蛋"); !found {
+			t.Error("added word was lost after reload")
+		}
+		if found, word := f.FindIn("废物"); found {
+			t.Errorf("removed word %s is back after reload", word)
+		}
+	}
+
+	// 新实例从存储加载
+	fresh := newFilter()
+	defer fresh.Close()
+	if found, _ := fresh.FindIn("笨蛋"); !found {
+		t.Error("overlay was not loaded from the store")
+	}
+	time.Sleep(50 * time.Millisecond) // 等待订阅
+	loads := store.loads.Load()
+	filter.AddWord("废物")
+	deadline = time.Now().Add(2 * time.Second)
+	for found, _ := fresh.FindIn("废物"); !found; found, _ = fresh.FindIn("废物") {
+		if time.Now().After(deadline) {
+			t.Fatal("change was not propagated to replicas")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := store.loads.Load() - loads; n != 2 {
+		// 只有 replica 和 fresh 重新加载，filter 忽略自己的通知
+		t.Errorf("overlay loaded %d times after a change, want 2", n)
+	}
+
+	// 运行时的修改只更新改动的词
+	filter.AddWord("垃圾\tads\treview", "傻瓜\tabuse\tallow")
+	if severity, _ := filter.Moderate("垃圾"); severity != SeverityReview {
+		t.Errorf("severity of a re-added word = %v", severity)
+	}
+	if found, _ := filter.FindIn("傻瓜"); found {
+		t.Error("word of severity allow is reported by FindIn")
+	}
+	dict := filter.dict.Load()
+	filter.DelWord("垃圾", "笨蛋")
+	if dict.get("垃圾") == nil {
+		t.Error("DelWord changed the dictionary in use")
+	}
+	for _, word := range []string{"垃圾", "笨蛋"} {
+		if found, _ := filter.FindIn(word); found {
+			t.Errorf("%s is still found after DelWord", word)
+		}
+		if matches := filter.Scan(word); len(matches) > 0 {
+			t.Errorf("%s is still scanned after DelWord", word)
+		}
+	}
+	if found, _ := filter.FindIn("蠢货"); !found {
+		t.Error("DelWord removed other words")
+	}
+
+	// 保存失败的修改在重新加载后仍然有效，并在下次加载时保存
+	store.fail.Store(true)
+	filter.AddWord("混蛋")
+	store.fail.Store(false)
+	replica.AddWord("白痴")
+	deadline = time.Now().Add(2 * time.Second)
+	for found, _ := filter.FindIn("白痴"); !found; found, _ = filter.FindIn("白痴") {
+		if time.Now().After(deadline) {
+			t.Fatal("change was not propagated to filter")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if found, _ := filter.FindIn("混蛋"); !found {
+		t.Error("unsaved word was lost after loading the overlay")
+	}
+	for found, _ := replica.FindIn("混蛋"); !found; found, _ = replica.FindIn("混蛋") {
+		if time.Now().After(deadline) {
+			t.Fatal("unsaved word was not saved again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dict := fstest.MapFS{"words.txt": {Data: []byte("垃圾\tabuse\tblock\n代开发票\tads\treview\n发票\tads\tallow\n法轮\tpolitics\n傻\n")}}
+	filter, err := New("",
+		WithSource(NewFSSource(dict, "words.txt"), time.Hour),
+		WithPolicy(CategoryAds, ActionRemove),
+		WithPolicy(CategoryPolitics, ActionKeep),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer filter.Close()
+
+	text := "你这垃圾，代开发票找我"
+	matches := filter.Scan(text)
+	want := []Match{
+		{Entry: Entry{Word: "垃圾", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "垃圾", Start: 2, End: 4},
+		{Entry: Entry{Word: "代开发票", Category: CategoryAds, Severity: SeverityReview}, Text: "代开发票", Start: 5, End: 9},
+		{Entry: Entry{Word: "发票", Category: CategoryAds, Severity: SeverityAllow}, Text: "发票", Start: 7, End: 9},
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("Scan() = %+v, want %+v", matches, want)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("Scan()[%d] = %+v, want %+v", i, matches[i], want[i])
+		}
+	}
+
+	if severity, _ := filter.Moderate("开发票"); severity != SeverityAllow {
+		t.Errorf("Moderate(开发票) = %s, want allow", severity)
+	}
+	if severity, _ := filter.Moderate("代开发票"); severity != SeverityReview {
+		t.Errorf("Moderate(代开发票) = %s, want review", severity)
+	}
+	if severity, _ := filter.Moderate(text); severity != SeverityBlock {
+		t.Errorf("Moderate() = %s, want block", severity)
+	}
+
+	for text, want := range map[string]string{
+		"你这垃圾，代开发票找我": "你这**，找我",
+		"法轮和傻子":       "法轮和*子",
+		"发票":          "发票",
+	} {
+		if got := filter.Replace(text, '*'); got != want {
+			t.Errorf("Replace(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if valid, word := filter.Validate("开发票"); !valid {
+		t.Errorf("Validate() = false, %s for a word of severity allow", word)
+	}
+
+	// 运行时添加带分类的词
+	filter.AddWord("赌场\tads\treview")
+	if matches := filter.Scan("去赌场"); len(matches) != 1 || matches[0].Severity != SeverityReview || matches[0].Start != 1 {
+		t.Errorf("Scan() after AddWord = %+v", matches)
+	}
+	filter.DelWord("赌场")
+	if matches := filter.Scan("去赌场"); len(matches) != 0 {
+		t.Errorf("Scan() after DelWord = %+v", matches)
+	}
+
+	// 噪音对 Scan、Moderate 和 Replace 同样有效
+	filter.UpdateNoisePattern(`[*]`)
+	if valid, _ := filter.Validate("垃*圾"); valid {
+		t.Error("Validate(垃*圾) = true with noise pattern")
+	}
+	if severity, matches := filter.Moderate("你这垃*圾"); severity != SeverityBlock || len(matches) != 1 || matches[0].Text != "垃*圾" || matches[0].Start != 2 || matches[0].End != 5 {
+		t.Errorf("Moderate(垃*圾) = %s, %+v", severity, matches)
+	}
+	if got := filter.Replace("你这垃**圾啊*", '#'); got != "你这####啊*" {
+		t.Errorf("Replace() with noise = %q", got)
+	}
+
+	if entry := ParseEntry("词\tPorn\treview"); entry != (Entry{Word: "词", Category: CategoryPorn, Severity: SeverityReview}) || entry.String() != "词\tporn\treview" {
+		t.Errorf("ParseEntry() = %+v", entry)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	dict := fstest.MapFS{"words.txt": {Data: []byte("垃圾\tabuse\n法轮功\tpolitics\nfuck\tabuse\n木齐\tabuse\n傻\tabuse\n傻逼\tabuse\n")}}
+	pinyin := map[rune]string{'法': "fa", '轮': "lun", '功': "gong", '傻': "sha", '逼': "bi"}
+	filter, err := New("",
+		WithSource(NewFSSource(dict, "words.txt"), time.Hour),
+		WithNormalization(NormalizeAll),
+		WithPinyin(func(r rune) string { return pinyin[r] }),
+		WithAllowlist("乌鲁木齐"),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer filter.Close()
+
+	for text, want := range map[string]Match{
+		"这是垃*圾啊":        {Entry: Entry{Word: "垃圾", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "垃*圾", Start: 2, End: 5},
+		"練習法輪功":         {Entry: Entry{Word: "法轮功", Category: CategoryPolitics, Severity: SeverityBlock}, Text: "法輪功", Start: 2, End: 5},
+		"ＦＵＣＫ you":      {Entry: Entry{Word: "fuck", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "ＦＵＣＫ", Start: 0, End: 4},
+		"fυсk":          {Entry: Entry{Word: "fuck", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "fυсk", Start: 0, End: 4},
+		"a f.u.c.k!":    {Entry: Entry{Word: "fuck", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "f.u.c.k", Start: 2, End: 9},
+		"学 Fa Lun Gong": {Entry: Entry{Word: "法轮功", Category: CategoryPolitics, Severity: SeverityBlock}, Text: "Fa Lun Gong", Start: 2, End: 13},
+		"木齐":            {Entry: Entry{Word: "木齐", Category: CategoryAbuse, Severity: SeverityBlock}, Text: "木齐", Start: 0, End: 2},
+	} {
+		if matches := filter.Scan(text); len(matches) != 1 || matches[0] != want {
+			t.Errorf("Scan(%q) = %+v, want %+v", text, matches, want)
+		}
+	}
+	// 单字没有拼音，拼音只在单词边界匹配
+	for _, text := range []string{"a shape in the shadow", "shabiness", "marshabi", "Falungongs", "sha"} {
+		if matches := filter.Scan(text); len(matches) != 0 {
+			t.Errorf("Scan(%q) = %+v, want no match", text, matches)
+		}
+	}
+	if matches := filter.Scan("你个sha bi!"); len(matches) != 1 || matches[0].Word != "傻逼" || matches[0].Text != "sha bi" {
+		t.Errorf("Scan() of spelled word = %+v", matches)
+	}
+	if matches := filter.Scan("我在乌鲁木齐"); len(matches) != 0 {
+		t.Errorf("Scan() of an allowed phrase = %+v", matches)
+	}
+	if got := filter.Replace("这是垃*圾啊", '*'); got != "这是***啊" {
+		t.Errorf("Replace() = %q", got)
+	}
+	if got := filter.Filter("这是垃-圾啊"); got != "这是啊" {
+		t.Errorf("Filter() = %q", got)
+	}
+	if valid, word := filter.Validate("f-u-c-k"); valid || word != "fuck" {
+		t.Errorf("Validate() = %v, %q", valid, word)
+	}
+	if got := filter.FindAll("垃圾 fuck 垃 圾"); len(got) != 2 {
+		t.Errorf("FindAll() = %v", got)
+	}
+	if found, _ := filter.FindIn("乌鲁木齐欢迎你"); found {
+		t.Error("FindIn() matched inside an allowed phrase")
+	}
+}
+
+func TestScanReader(t *testing.T) {
+	dict := fstest.MapFS{"words.txt": {Data: []byte("垃圾\tabuse\n代开发票\tads\treview\nfuck\tabuse\n")}}
+	for _, normalization := range []Normalization{0, NormalizeAll} {
+		filter, err := New("",
+			WithSource(NewFSSource(dict, "words.txt"), time.Hour),
+			WithNormalization(normalization),
+			WithPolicy(CategoryAds, ActionRemove),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		defer filter.Close()
+
+		// 敏感词跨越读取的边界
+		var text strings.Builder
+		for i := 0; i < 5; i++ {
+			text.WriteString(strings.Repeat("好", streamChunk-3+i))
+			text.WriteString("垃*圾，代开发票")
+		}
+		want := filter.Scan(text.String())
+		if len(want) < 5 {
+			t.Fatalf("Scan() found %d matches, want at least 5", len(want))
+		}
+
+		var got []Match
+		err = filter.ScanReader(iotest.OneByteReader(strings.NewReader(text.String())), func(match Match) error {
+			got = append(got, match)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanReader() error = %v", err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("ScanReader() found %d matches, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("ScanReader()[%d] = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+
+		var out strings.Builder
+		if err := filter.ReplaceStream(&out, strings.NewReader(text.String()), '*'); err != nil {
+			t.Fatalf("ReplaceStream() error = %v", err)
+		}
+		if replaced := filter.Replace(text.String(), '*'); out.String() != replaced {
+			t.Errorf("ReplaceStream() differs from Replace(), %d and %d bytes", out.Len(), len(replaced))
+		}
+
+		// 回调的错误中止扫描
+		stop := fmt.Errorf("stop")
+		count := 0
+		err = filter.ScanReader(strings.NewReader(text.String()), func(Match) error {
+			count++
+			return stop
+		})
+		if err != stop || count != 1 {
+			t.Errorf("ScanReader() = %v after %d matches", err, count)
+		}
+	}
+
+	// 每个字之间插入多个符号，跨越读取的边界
+	filter, err := New("", WithSource(NewFSSource(dict, "words.txt"), time.Hour), WithNormalization(NormalizeAll))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer filter.Close()
+	for _, word := range []string{"f . u . c . k", "f\u200b\u200b\u200bu\u200b\u200b\u200bc\u200b\u200b\u200bk", "垃 * * * 圾"} {
+		for i := 0; i <= len([]rune(word)); i++ {
+			text := strings.Repeat("好", streamChunk-i) + word + "好"
+			var got []Match
+			err := filter.ScanReader(strings.NewReader(text), func(match Match) error {
+				got = append(got, match)
+				return nil
+			})
+			if err != nil || len(got) != 1 || got[0].Text != word {
+				t.Errorf("ScanReader() of %q at %d = %+v, %v", word, streamChunk-i, got, err)
+			}
+			var out strings.Builder
+			if err := filter.ReplaceStream(&out, strings.NewReader(text), '*'); err != nil || out.String() != filter.Replace(text, '*') {
+				t.Errorf("ReplaceStream() of %q at %d differs from Replace()", word, streamChunk-i)
+			}
+		}
+	}
+	// 只有符号的长文本不会一直留在内存中
+	for _, prefix := range []string{"", "fu"} {
+		text := prefix + strings.Repeat("-", 2<<20) + "fuck"
+		var before runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		peak := uint64(0)
+		reader := &sampleReader{Reader: strings.NewReader(text), sample: func() {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapAlloc)
+		}}
+		start := time.Now()
+		var got []Match
+		err := filter.ScanReader(reader, func(match Match) error {
+			got = append(got, match)
+			return nil
+		})
+		if err != nil || len(got) != 1 || got[0].Text != "fuck" {
+			t.Errorf("ScanReader() of separators = %+v, %v", got, err)
+		}
+		if elapsed := time.Since(start); elapsed > 30*time.Second {
+			t.Errorf("ScanReader() of 2 MiB of separators took %s", elapsed)
+		}
+		if peak > before.HeapAlloc+64<<20 {
+			t.Errorf("ScanReader() of 2 MiB of separators used %d MiB", (peak-before.HeapAlloc)>>20)
+		}
+	}
+}
+
+// sampleReader call sample every 64 KiB read
+type sampleReader struct {
+	io.Reader
+	sample func()
+	read   int
+}
+
+func (r *sampleReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if r.read/(64<<10) != (r.read+n)/(64<<10) {
+		r.sample()
+	}
+	r.read += n
+	return n, err
+}
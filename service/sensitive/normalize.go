@@ -0,0 +1,239 @@
+package sensitive
+
+import (
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/longbridgeapp/opencc"
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Normalization steps applied to the dictionary and the text before Scan,
+// so that variants of a word still match
+type Normalization int
+
+// Normalization steps, in the order they are applied
+const (
+	NormalizeTraditional Normalization = 1 << iota // 繁体转简体 (opencc t2s)
+	NormalizeWidth                                 // 全角转半角，并转小写
+	NormalizeConfusables                           // 去掉变音符号，同形字转为拉丁字母，如西里尔字母 а
+	NormalizeLeetspeak                             // 0 => o，3 => e，@ => a 等
+	NormalizeSymbols                               // 去掉插入的符号和空白，如 垃*圾
+
+	NormalizeAll = NormalizeTraditional | NormalizeWidth | NormalizeConfusables | NormalizeLeetspeak | NormalizeSymbols
+)
+
+// WithNormalization normalize the dictionary and the text before matching,
+// Scan still reports offsets in the original text. Filter, FindIn, Validate
+// and FindAll also match with Scan then.
+func WithNormalization(steps Normalization) Option {
+	return func(sf *SensitiveFilter) {
+		sf.normalizer.steps = steps
+	}
+}
+
+// WithPinyin also match words written in pinyin, pinyin returns the
+// pinyin of a Han character without tone, such as "fa" for 法, or "" when unknown
+func WithPinyin(pinyin func(r rune) string) Option {
+	return func(sf *SensitiveFilter) {
+		sf.normalizer.pinyin = pinyin
+	}
+}
+
+// WithAllowlist never report matches inside these words, for place names
+// and other phrases that contain a sensitive word
+func WithAllowlist(words ...string) Option {
+	return func(sf *SensitiveFilter) {
+		sf.allowlist = append(sf.allowlist, words...)
+	}
+}
+
+// confusables characters that look like Latin letters
+var confusables = map[rune]rune{
+	// 西里尔字母
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's',
+	// 希腊字母
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// 其他
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ß': 's',
+}
+
+// leetspeak digits and symbols used for letters
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't',
+}
+
+var (
+	t2s      *opencc.OpenCC
+	t2sOnce  sync.Once
+	t2sCache sync.Map // rune => rune
+)
+
+// simplify convert a traditional character, only one-to-one conversions are used
+func simplify(r rune) rune {
+	if !unicode.Is(unicode.Han, r) {
+		return r
+	}
+	if s, ok := t2sCache.Load(r); ok {
+		return s.(rune)
+	}
+	t2sOnce.Do(func() {
+		t2s, _ = opencc.New("t2s")
+	})
+	s := r
+	if t2s != nil {
+		if out, err := t2s.Convert(string(r)); err == nil && utf8.RuneCountInString(out) == 1 {
+			s, _ = utf8.DecodeRuneInString(out)
+		}
+	}
+	t2sCache.Store(r, s)
+	return s
+}
+
+// token a normalized character and the runes of the original text it comes from
+type token struct {
+	r     rune
+	start int
+	end   int
+}
+
+// normalizer the normalization pipeline
+type normalizer struct {
+	steps  Normalization
+	pinyin func(r rune) string
+}
+
+// enabled whether the text needs to be normalized
+func (n *normalizer) enabled() bool {
+	return n.steps != 0
+}
+
+// tokens normalize runes, a character may become none or several tokens
+func (n *normalizer) tokens(runes []rune) []token {
+	tokens := make([]token, 0, len(runes))
+	for i, r := range runes {
+		if n.steps&NormalizeTraditional != 0 {
+			r = simplify(r)
+		}
+		folded := []rune{r}
+		if n.steps&NormalizeWidth != 0 {
+			folded = []rune(width.Narrow.String(string(r)))
+		}
+		if n.steps&NormalizeConfusables != 0 {
+			folded = []rune(norm.NFKD.String(string(folded)))
+		}
+		for _, r := range folded {
+			if n.steps&NormalizeWidth != 0 {
+				r = unicode.ToLower(r)
+			}
+			if n.steps&NormalizeConfusables != 0 {
+				if unicode.Is(unicode.Mn, r) {
+					continue
+				}
+				if c, ok := confusables[unicode.ToLower(r)]; ok {
+					r = c
+				}
+			}
+			if n.steps&NormalizeLeetspeak != 0 {
+				if c, ok := leetspeak[r]; ok {
+					r = c
+				}
+			}
+			if n.steps&NormalizeSymbols != 0 && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				continue
+			}
+			tokens = append(tokens, token{r: r, start: i, end: i + 1})
+		}
+	}
+	return tokens
+}
+
+// keys the normalized form of a dictionary word => false, and its pinyin =>
+// true when enabled. Words of a single Han character have no pinyin key, a
+// syllable such as "sha" is too common in English words.
+func (n *normalizer) keys(word string) map[string]bool {
+	keys := map[string]bool{n.normalize(word): false}
+	if n.pinyin == nil {
+		return keys
+	}
+	var spelled []rune
+	han := 0
+	for _, r := range word {
+		if p := n.pinyin(r); p != "" && unicode.Is(unicode.Han, r) {
+			spelled = append(spelled, []rune(p)...)
+			han++
+		} else {
+			spelled = append(spelled, r)
+		}
+	}
+	if han >= 2 {
+		keys[n.normalize(string(spelled))] = true
+	}
+	return keys
+}
+
+// normalize a word
+func (n *normalizer) normalize(word string) string {
+	tokens := n.tokens([]rune(word))
+	runes := make([]rune, len(tokens))
+	for i, t := range tokens {
+		runes[i] = t.r
+	}
+	return string(runes)
+}
+
+// scan find the dictionary words in the normalized text, dropping those inside
+// an allowed phrase, offsets are mapped back to the original runes
+func (n *normalizer) scan(dict, allow *dictionary, runes []rune) []Match {
+	tokens := n.tokens(runes)
+	normalized := make([]rune, len(tokens))
+	for i, t := range tokens {
+		normalized[i] = t.r
+	}
+
+	var allowed []bool
+	if allow != nil {
+		allowed = make([]bool, len(normalized))
+		for _, m := range allow.scan(normalized, nil) {
+			for i := m.Start; i < m.End; i++ {
+				allowed[i] = true
+			}
+		}
+	}
+
+	// 拼音前后不能是字母，否则 shape 中也有 sha
+	boundary := func(start, end int) bool {
+		before, after := tokens[start].start-1, tokens[end-1].end
+		return (before < 0 || !unicode.Is(unicode.Latin, runes[before])) &&
+			(after >= len(runes) || !unicode.Is(unicode.Latin, runes[after]))
+	}
+	var matches []Match
+	for _, m := range dict.scan(normalized, boundary) {
+		if allowed != nil && isAllowed(allowed[m.Start:m.End]) {
+			continue
+		}
+		m.Start, m.End = tokens[m.Start].start, tokens[m.End-1].end
+		if last := len(matches) - 1; last >= 0 && matches[last].Start == m.Start && matches[last].End == m.End {
+			// 一个字符展开成多个时会重复匹配
+			continue
+		}
+		m.Text = string(runes[m.Start:m.End])
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// isAllowed every character is inside an allowed phrase
+func isAllowed(allowed []bool) bool {
+	for _, ok := range allowed {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,344 @@
+package sensitive
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"github.com/mylukin/EchoPilot/storage/redis"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Overlay the words added and removed at runtime, re-applied after every reload
+type Overlay struct {
+	Added   []string // 词典行，见 ParseEntry
+	Removed []string
+}
+
+// OverlayStore share the overlay across replicas
+type OverlayStore interface {
+	// Load return the whole overlay
+	Load(ctx context.Context) (Overlay, error)
+	// Save persist that words were added or removed and notify the watchers,
+	// added words are dictionary lines that may carry a category and severity.
+	// origin identifies the replica that made the change.
+	Save(ctx context.Context, origin string, words []string, added bool) error
+	// Watch call onChange with the origin of the change whenever the overlay
+	// is changed, until ctx is done
+	Watch(ctx context.Context, onChange func(origin string)) error
+}
+
+// WithOverlayStore persist AddWord and DelWord to store, changes made by other
+// replicas are applied as they are notified
+func WithOverlayStore(store OverlayStore) Option {
+	return func(sf *SensitiveFilter) {
+		sf.overlayStore = store
+	}
+}
+
+// setOverlay record and apply runtime changes, a word is either added or removed
+func (sf *SensitiveFilter) setOverlay(lines []string, added bool) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	before := map[string]*Entry{}
+	for _, line := range lines {
+		word := ParseEntry(line).Word
+		if _, ok := before[word]; !ok {
+			before[word] = sf.entry(word)
+		}
+		if added {
+			sf.added[word] = line
+			delete(sf.removed, word)
+		} else {
+			sf.removed[word] = true
+			delete(sf.added, word)
+		}
+	}
+	sf.apply(before)
+}
+
+// saveOverlay persist runtime changes when a store is configured, changes
+// that failed to save are kept pending and saved again on the next load
+func (sf *SensitiveFilter) saveOverlay(words []string, added bool) {
+	if sf.overlayStore == nil {
+		return
+	}
+	err := sf.overlayStore.Save(sf.ctx, sf.id, words, added)
+	if err != nil {
+		log.Errorf("Failed to save sensitive words: %v", err)
+	}
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	for _, line := range words {
+		word := ParseEntry(line).Word
+		switch {
+		case err == nil:
+			delete(sf.pending, word)
+		case added:
+			sf.pending[word] = line
+		default:
+			sf.pending[word] = ""
+		}
+	}
+}
+
+// savePending save the changes that failed to save before
+func (sf *SensitiveFilter) savePending() {
+	sf.mu.Lock()
+	var added, removed []string
+	for word, line := range sf.pending {
+		if line != "" {
+			added = append(added, line)
+		} else {
+			removed = append(removed, word)
+		}
+	}
+	sf.mu.Unlock()
+	if len(added) > 0 {
+		sf.saveOverlay(added, true)
+	}
+	if len(removed) > 0 {
+		sf.saveOverlay(removed, false)
+	}
+}
+
+// loadOverlay replace the overlay with the one in the store, the changed
+// words are applied to the current filter when apply is true, otherwise the
+// caller rebuilds it
+func (sf *SensitiveFilter) loadOverlay(apply bool) error {
+	sf.savePending()
+	overlay, err := sf.overlayStore.Load(sf.ctx)
+	if err != nil {
+		return err
+	}
+	added := map[string]string{}
+	for _, line := range overlay.Added {
+		added[ParseEntry(line).Word] = line
+	}
+	removed := map[string]bool{}
+	for _, word := range overlay.Removed {
+		removed[word] = true
+	}
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// 未保存的修改优先
+	for word, line := range sf.pending {
+		if line != "" {
+			added[word] = line
+			delete(removed, word)
+		} else {
+			removed[word] = true
+			delete(added, word)
+		}
+	}
+	before := map[string]*Entry{}
+	if apply {
+		for _, words := range []map[string]string{sf.added, added} {
+			for word := range words {
+				before[word] = sf.entry(word)
+			}
+		}
+		for _, words := range []map[string]bool{sf.removed, removed} {
+			for word := range words {
+				before[word] = sf.entry(word)
+			}
+		}
+	}
+	sf.added, sf.removed = added, removed
+	if apply {
+		sf.apply(before)
+	}
+	return nil
+}
+
+// watchOverlay reload the overlay when another replica changes it
+func (sf *SensitiveFilter) watchOverlay() {
+	for {
+		err := sf.overlayStore.Watch(sf.ctx, func(origin string) {
+			if origin == sf.id {
+				// 自己保存的修改已经生效
+				return
+			}
+			if err := sf.loadOverlay(true); err != nil {
+				log.Errorf("Failed to load sensitive words: %v", err)
+			}
+		})
+		select {
+		case <-sf.ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+			// 连接断开后重新订阅
+			log.Warnf("Sensitive words watch stopped, retrying: %v", err)
+		}
+	}
+}
+
+// RedisOverlayStore keep the overlay in Redis and notify with pub/sub
+type RedisOverlayStore struct {
+	Key string // 哈希 <Key>:added 为词 => 词典行，集合 <Key>:removed，频道 <Key>:changed
+}
+
+// NewRedisOverlayStore create a Redis store, key defaults to "sensitive:overlay"
+func NewRedisOverlayStore(key string) *RedisOverlayStore {
+	if key == "" {
+		key = "sensitive:overlay"
+	}
+	return &RedisOverlayStore{Key: key}
+}
+
+// Load read the added and removed words
+func (s *RedisOverlayStore) Load(ctx context.Context) (Overlay, error) {
+	added, err := redis.GetRedis().HVals(ctx, redis.GetCacheKey(s.Key+":added")).Result()
+	if err != nil {
+		return Overlay{}, err
+	}
+	removed, err := redis.GetRedis().SMembers(ctx, redis.GetCacheKey(s.Key+":removed")).Result()
+	if err != nil {
+		return Overlay{}, err
+	}
+	return Overlay{Added: added, Removed: removed}, nil
+}
+
+// Save move the words between added and removed in a transaction and publish the change
+func (s *RedisOverlayStore) Save(ctx context.Context, origin string, words []string, added bool) error {
+	if len(words) == 0 {
+		return nil
+	}
+	addedKey, removedKey := redis.GetCacheKey(s.Key+":added"), redis.GetCacheKey(s.Key+":removed")
+	members := make([]any, len(words))
+	fields := make([]string, len(words))
+	values := make([]any, 0, len(words)*2)
+	for i, line := range words {
+		word := ParseEntry(line).Word
+		members[i], fields[i] = word, word
+		values = append(values, word, line)
+	}
+	pipe := redis.TxPipeline()
+	if added {
+		pipe.HSet(ctx, addedKey, values...)
+		pipe.SRem(ctx, removedKey, members...)
+	} else {
+		pipe.SAdd(ctx, removedKey, members...)
+		pipe.HDel(ctx, addedKey, fields...)
+	}
+	pipe.Publish(ctx, redis.GetCacheKey(s.Key+":changed"), origin)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Watch subscribe to the change channel, the messages are the origins
+func (s *RedisOverlayStore) Watch(ctx context.Context, onChange func(origin string)) error {
+	pubsub := redis.Subscribe(s.Key + ":changed")
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return errors.New("redis subscription closed")
+			}
+			onChange(message.Payload)
+		}
+	}
+}
+
+// MongoOverlayStore keep the overlay in a Mongo collection, one document per
+// word, changes are watched with a change stream, which needs a replica set
+type MongoOverlayStore struct {
+	Collection string
+	URI        []string // 默认使用 MONGO_URI
+}
+
+// overlayDoc a word of the overlay
+type overlayDoc struct {
+	Word    string    `bson:"word"`
+	Line    string    `bson:"line"` // 词典行，见 ParseEntry
+	Added   bool      `bson:"added"`
+	Origin  string    `bson:"origin"` // 修改的副本
+	Updated time.Time `bson:"updated"`
+}
+
+// NewMongoOverlayStore create a Mongo store, collection defaults to "sensitive_overlay"
+func NewMongoOverlayStore(collection string, uri ...string) *MongoOverlayStore {
+	if collection == "" {
+		collection = "sensitive_overlay"
+	}
+	return &MongoOverlayStore{Collection: collection, URI: uri}
+}
+
+// Load read all documents
+func (s *MongoOverlayStore) Load(ctx context.Context) (Overlay, error) {
+	collection := mongo.C(s.Collection, s.URI...).Get()
+	if collection == nil {
+		return Overlay{}, errors.New("mongo is not configured")
+	}
+	cursor, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return Overlay{}, err
+	}
+	var docs []overlayDoc
+	if err := cursor.All(ctx, &docs); err != nil {
+		return Overlay{}, err
+	}
+
+	var overlay Overlay
+	for _, doc := range docs {
+		if doc.Added && doc.Line != "" {
+			overlay.Added = append(overlay.Added, doc.Line)
+		} else if doc.Added {
+			overlay.Added = append(overlay.Added, doc.Word)
+		} else {
+			overlay.Removed = append(overlay.Removed, doc.Word)
+		}
+	}
+	return overlay, nil
+}
+
+// Save upsert a document per word
+func (s *MongoOverlayStore) Save(ctx context.Context, origin string, words []string, added bool) error {
+	collection := mongo.C(s.Collection, s.URI...)
+	if collection.Get() == nil {
+		return errors.New("mongo is not configured")
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	builder := collection.NewBulkBuilder()
+	now := time.Now()
+	for _, line := range words {
+		word := ParseEntry(line).Word
+		builder.Upsert(bson.D{{Key: "word", Value: word}}, bson.M{"$set": overlayDoc{Word: word, Line: line, Added: added, Origin: origin, Updated: now}})
+	}
+	_, err := builder.Execute()
+	return err
+}
+
+// Watch open a change stream on the collection, the origin is read from the
+// changed document
+func (s *MongoOverlayStore) Watch(ctx context.Context, onChange func(origin string)) error {
+	collection := mongo.C(s.Collection, s.URI...).Get()
+	if collection == nil {
+		return errors.New("mongo is not configured")
+	}
+	stream, err := collection.Watch(ctx, bson.A{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+	for stream.Next(ctx) {
+		var event struct {
+			FullDocument overlayDoc `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			log.Warnf("Failed to decode sensitive words change: %v", err)
+		}
+		onChange(event.FullDocument.Origin)
+	}
+	return stream.Err()
+}
@@ -0,0 +1,276 @@
+package sensitive
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/mylukin/EchoPilot/storage/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Source a dictionary of sensitive words
+type Source interface {
+	// Name identify the source in logs
+	Name() string
+	// Fetch return the lines of the dictionary, changed is false when it is
+	// the same as the last successful fetch and lines is then nil
+	Fetch(ctx context.Context) (lines []string, changed bool, err error)
+}
+
+// readLines read a dictionary, one word per line
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// HTTPSource a dictionary served over HTTP, revalidated with ETag and Last-Modified
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	dump         func(data []byte, isRequest bool) // 调试模式下输出请求和响应
+}
+
+// NewHTTPSource create an HTTP source, client defaults to a 10s timeout
+func NewHTTPSource(url string, client *http.Client) *HTTPSource {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSource{URL: url, Client: client}
+}
+
+// Name is the URL
+func (s *HTTPSource) Name() string {
+	return s.URL
+}
+
+// Fetch send a conditional GET, 304 Not Modified means unchanged
+func (s *HTTPSource) Fetch(ctx context.Context) ([]string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %v", err)
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.lastModified != "" {
+		req.Header.Set("If-Modified-Since", s.lastModified)
+	}
+	if s.dump != nil {
+		if reqDump, err := httputil.DumpRequestOut(req, true); err != nil {
+			log.Errorf("Failed to dump request: %v", err)
+		} else {
+			s.dump(reqDump, true)
+		}
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if s.dump != nil {
+		if respDump, err := httputil.DumpResponse(resp, false); err != nil {
+			log.Errorf("Failed to dump response: %v", err)
+		} else {
+			s.dump(respDump, false)
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to fetch dictionary, status code: %d", resp.StatusCode)
+	}
+
+	lines, err := readLines(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	s.etag = resp.Header.Get("ETag")
+	s.lastModified = resp.Header.Get("Last-Modified")
+	return lines, true, nil
+}
+
+// FileSource a dictionary file on disk, reloaded when its size or modification time changes
+type FileSource struct {
+	Path string
+
+	mu      sync.Mutex
+	size    int64
+	modTime time.Time
+}
+
+// NewFileSource create a file source
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+// Name is the path
+func (s *FileSource) Name() string {
+	return "file://" + s.Path
+}
+
+// Fetch read the file when it changed
+func (s *FileSource) Fetch(ctx context.Context) ([]string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	if info.Size() == s.size && info.ModTime().Equal(s.modTime) {
+		return nil, false, nil
+	}
+
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, false, err
+	}
+	s.size, s.modTime = info.Size(), info.ModTime()
+	return lines, true, nil
+}
+
+// FSSource a dictionary in an fs.FS such as embed.FS, read once
+type FSSource struct {
+	FS   fs.FS
+	Path string
+
+	mu     sync.Mutex
+	loaded bool
+}
+
+// NewFSSource create a source of a file in fsys
+func NewFSSource(fsys fs.FS, path string) *FSSource {
+	return &FSSource{FS: fsys, Path: path}
+}
+
+// Name is the path
+func (s *FSSource) Name() string {
+	return "fs://" + s.Path
+}
+
+// Fetch read the file on the first call only, the content of an embed.FS never
+// changes, it is read again after a failure
+func (s *FSSource) Fetch(ctx context.Context) ([]string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loaded {
+		return nil, false, nil
+	}
+	file, err := s.FS.Open(s.Path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	lines, err := readLines(file)
+	if err != nil {
+		return nil, false, err
+	}
+	s.loaded = true
+	return lines, true, nil
+}
+
+// MongoSource the words stored in a field of a Mongo collection, with the
+// optional "category" and "severity" fields of the documents
+type MongoSource struct {
+	Collection string
+	Field      string
+	URI        []string // 默认使用 MONGO_URI
+
+	mu   sync.Mutex
+	hash [sha1.Size]byte
+}
+
+// NewMongoSource create a source of the field of every document in collection
+func NewMongoSource(collection, field string, uri ...string) *MongoSource {
+	return &MongoSource{Collection: collection, Field: field, URI: uri}
+}
+
+// Name is the collection and field
+func (s *MongoSource) Name() string {
+	return "mongo://" + s.Collection + "/" + s.Field
+}
+
+// Fetch read the field of all documents, unchanged when the words are the same
+func (s *MongoSource) Fetch(ctx context.Context) ([]string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection := mongo.C(s.Collection, s.URI...).Get()
+	if collection == nil {
+		return nil, false, errors.New("mongo is not configured")
+	}
+	projection := bson.D{{Key: s.Field, Value: 1}, {Key: "category", Value: 1}, {Key: "severity", Value: 1}}
+	cursor, err := collection.Find(ctx, bson.D{}, options.Find().SetProjection(projection))
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var lines []string
+	hash := sha1.New()
+	for cursor.Next(ctx) {
+		word, ok := cursor.Current.Lookup(s.Field).StringValueOK()
+		if word = strings.TrimSpace(word); !ok || word == "" {
+			continue
+		}
+		entry := Entry{Word: word, Severity: SeverityBlock}
+		if category, ok := cursor.Current.Lookup("category").StringValueOK(); ok {
+			entry.Category = Category(category)
+		}
+		if severity, ok := cursor.Current.Lookup("severity").StringValueOK(); ok {
+			if entry.Severity, err = ParseSeverity(severity); err != nil {
+				log.Warnf("%s: %v", word, err)
+			}
+		}
+		line := entry.String()
+		lines = append(lines, line)
+		hash.Write([]byte(line + "\n"))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var sum [sha1.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	if sum == s.hash {
+		return nil, false, nil
+	}
+	s.hash = sum
+	return lines, true, nil
+}
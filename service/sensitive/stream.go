@@ -0,0 +1,161 @@
+package sensitive
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// streamChunk runes read from the reader between two scans
+const streamChunk = 4096
+
+// streamWindow characters kept between chunks so that matches across chunk
+// boundaries are found, as long as the longest word. Characters are counted
+// after removing noise and normalizing, see positions.
+func (sf *SensitiveFilter) streamWindow(dict *dictionary) int {
+	window := dict.longest
+	if sf.allow != nil {
+		window = max(window, sf.allow.longest)
+	}
+	return max(window, 1)
+}
+
+// positions the position in buf of each character matched against the
+// dictionary, noise and the symbols dropped by the normalizer are skipped,
+// so any number of them may be inserted in a word
+func (sf *SensitiveFilter) positions(buf []rune) []int {
+	kept, index := sf.removeNoise(buf)
+	positions := make([]int, 0, len(kept))
+	if sf.normalizing() {
+		for _, t := range sf.normalizer.tokens(kept) {
+			positions = append(positions, t.start)
+		}
+	} else {
+		for i := range kept {
+			positions = append(positions, i)
+		}
+	}
+	if index != nil {
+		for i, p := range positions {
+			positions[i] = index[p]
+		}
+	}
+	return positions
+}
+
+// stream read r chunk by chunk and call handle with the matches starting in
+// buf[from:to], runes before to are final. base is the offset of buf[0] in
+// the stream, buf also keeps a window before from for allowed phrases.
+func (sf *SensitiveFilter) stream(r io.Reader, handle func(buf []rune, base, from, to int, matches []Match) error) error {
+	dict := sf.dict.Load()
+	if dict == nil {
+		dict = newDictionary()
+	}
+	window := sf.streamWindow(dict)
+	reader := bufio.NewReader(r)
+
+	var buf []rune
+	base, from := 0, 0
+	for eof := false; !eof; {
+		for n := 0; n < streamChunk; n++ {
+			c, _, err := reader.ReadRune()
+			if err == io.EOF {
+				eof = true
+				break
+			}
+			if err != nil {
+				return err
+			}
+			buf = append(buf, c)
+		}
+
+		// 归一化后的最后 window 个字符留到下次，之间的符号不计数
+		to, kept := len(buf), 0
+		if !eof {
+			positions := sf.positions(buf)
+			// 未处理的字符不足 window 个时，只确定它们之前的符号
+			if i := max(len(positions)-window, sort.SearchInts(positions, from)); i < len(positions) {
+				to = positions[i]
+			}
+			// 连续的符号太长时不再等待，避免 buf 无限增长
+			to = max(to, len(buf)-streamChunk)
+			k := sort.SearchInts(positions, to)
+			kept = to
+			if k > 0 {
+				kept = positions[max(k-window, 0)]
+			}
+			kept = max(kept, to-streamChunk)
+		}
+		if to <= from {
+			continue
+		}
+		var matches []Match
+		for _, match := range sf.scan(dict, buf) {
+			if match.Start >= from && match.Start < to {
+				matches = append(matches, match)
+			}
+		}
+		if err := handle(buf, base, from, to, matches); err != nil {
+			return err
+		}
+
+		// 保留 to 之前的 window 个字符用于检查白名单，再多一个用于检查拼音的单词边界
+		drop := max(kept-1, 0)
+		buf = append(buf[:0], buf[drop:]...)
+		base += drop
+		from = to - drop
+	}
+	return nil
+}
+
+// ScanReader scan the text read from r without loading it into memory, fn
+// is called for each match as soon as it is found, in order of position.
+// Offsets are runes from the start of the stream, an error of fn stops the scan.
+// Unlike Scan, a word split by more than streamChunk runes of symbols or noise
+// is not found.
+func (sf *SensitiveFilter) ScanReader(r io.Reader, fn func(Match) error) error {
+	return sf.stream(r, func(buf []rune, base, from, to int, matches []Match) error {
+		for _, match := range matches {
+			match.Start += base
+			match.End += base
+			if err := fn(match); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReplaceStream copy r to w, replacing the sensitive words like Replace
+func (sf *SensitiveFilter) ReplaceStream(w io.Writer, r io.Reader, repl rune) error {
+	writer := bufio.NewWriter(w)
+	actions := map[int]Action{} // 流中的位置 => 处理方式，重叠时删除优先于替换
+	err := sf.stream(r, func(buf []rune, base, from, to int, matches []Match) error {
+		for _, match := range matches {
+			action := sf.policies[match.Category]
+			if match.Severity == SeverityAllow || action == ActionKeep {
+				continue
+			}
+			for i := match.Start + base; i < match.End+base; i++ {
+				if actions[i] != ActionRemove {
+					actions[i] = action
+				}
+			}
+		}
+		for i := from; i < to; i++ {
+			action, ok := actions[base+i]
+			delete(actions, base+i)
+			switch {
+			case !ok:
+				writer.WriteRune(buf[i])
+			case action == ActionMask:
+				writer.WriteRune(repl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
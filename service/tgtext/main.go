@@ -0,0 +1,299 @@
+package tgtext
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	tagRegex  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9-]*)((?:\s+[^<>]*?)?)\s*(/?)>`)
+	attrRegex = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9_:-]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+	langRegex = regexp.MustCompile(`^language-[\w+#.-]+$`)
+)
+
+// tagAlias map HTML tags to the canonical Telegram tag
+var tagAlias = map[string]string{
+	"b":          "b",
+	"strong":     "b",
+	"i":          "i",
+	"em":         "i",
+	"u":          "u",
+	"ins":        "u",
+	"s":          "s",
+	"strike":     "s",
+	"del":        "s",
+	"a":          "a",
+	"code":       "code",
+	"pre":        "pre",
+	"tg-spoiler": "tg-spoiler",
+	"blockquote": "blockquote",
+}
+
+// EscapeHTML escape the characters Telegram requires in HTML mode
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeMarkdownV2 escape text outside of entities in MarkdownV2 mode
+func EscapeMarkdownV2(s string) string {
+	return mdV2Escaper.Replace(s)
+}
+
+var mdV2Escaper = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// escapeMarkdownV2Code escape text inside pre and code entities
+func escapeMarkdownV2Code(s string) string {
+	return mdV2CodeEscaper.Replace(s)
+}
+
+var mdV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// escapeMarkdownV2URL escape the URL part of an inline link
+func escapeMarkdownV2URL(s string) string {
+	return mdV2URLEscaper.Replace(s)
+}
+
+var mdV2URLEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+
+// IsSafeURL link schemes accepted by Telegram
+func IsSafeURL(link string) bool {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp":
+		return u.Host != ""
+	case "tg", "mailto", "tel":
+		return u.Opaque != "" || u.Host != ""
+	}
+	return false
+}
+
+// element an open tag while sanitizing
+type element struct {
+	name  string // 闭合时匹配的名称
+	tag   string // 输出的标签，为空时不输出
+	attrs string
+}
+
+func (e element) open() string {
+	if e.tag == "" {
+		return ""
+	}
+	return "<" + e.tag + e.attrs + ">"
+}
+
+func (e element) close() string {
+	if e.tag == "" {
+		return ""
+	}
+	return "</" + e.tag + ">"
+}
+
+// Sanitize keep only the HTML subset supported by Telegram
+//
+// Tags outside the allowlist are removed while keeping their text, aliases
+// such as <strong> are rewritten to <b>, links with unsafe schemes are
+// dropped, entities are normalized and unbalanced tags are closed or
+// reopened so the result is always well formed.
+func Sanitize(s string) string {
+	var out strings.Builder
+	stack := []element{}
+
+	// 是否在 pre/code 中，其中不允许其他实体
+	inCode := func() bool {
+		for _, e := range stack {
+			if e.tag == "code" || e.tag == "pre" {
+				return true
+			}
+		}
+		return false
+	}
+	has := func(tag string) bool {
+		for _, e := range stack {
+			if e.tag == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			out.WriteString(EscapeHTML(html.UnescapeString(s)))
+			break
+		}
+		out.WriteString(EscapeHTML(html.UnescapeString(s[:i])))
+		s = s[i:]
+
+		// 注释
+		if strings.HasPrefix(s, "<!--") {
+			if end := strings.Index(s, "-->"); end >= 0 {
+				s = s[end+3:]
+			} else {
+				s = ""
+			}
+			continue
+		}
+
+		m := tagRegex.FindStringSubmatch(s)
+		if m == nil {
+			out.WriteString("&lt;")
+			s = s[1:]
+			continue
+		}
+		s = s[len(m[0]):]
+		closing, name, attrs := m[1] == "/", strings.ToLower(m[2]), m[3]
+
+		// script/style 的内容一并移除
+		if !closing && (name == "script" || name == "style") {
+			if end := strings.Index(strings.ToLower(s), "</"+name); end >= 0 {
+				s = s[end:]
+				if gt := strings.IndexByte(s, '>'); gt >= 0 {
+					s = s[gt+1:]
+				} else {
+					s = ""
+				}
+			} else {
+				s = ""
+			}
+			continue
+		}
+
+		switch name {
+		case "br":
+			out.WriteString("\n")
+			continue
+		case "p", "div", "li", "tr", "h1", "h2", "h3", "h4", "h5", "h6":
+			if closing {
+				out.WriteString("\n")
+			}
+			continue
+		}
+
+		tag, ok := tagAlias[name]
+		if name == "span" {
+			// 只有 tg-spoiler 的 span 输出，其他的仅用于匹配闭合标签
+			tag, ok = "", true
+			if hasClass(attrs, "tg-spoiler") && !inCode() {
+				tag = "tg-spoiler"
+			}
+		}
+		if !ok {
+			continue
+		}
+		match := tag
+		if name == "span" {
+			match = name
+		}
+
+		if closing {
+			// 找到最近的同名标签，关闭中间的标签后再重新打开
+			at := -1
+			for j := len(stack) - 1; j >= 0; j-- {
+				if stack[j].name == match {
+					at = j
+					break
+				}
+			}
+			if at < 0 {
+				continue
+			}
+			for j := len(stack) - 1; j >= at; j-- {
+				out.WriteString(stack[j].close())
+			}
+			reopen := stack[at+1:]
+			stack = stack[:at]
+			for _, e := range reopen {
+				out.WriteString(e.open())
+			}
+			stack = append(stack, reopen...)
+			continue
+		}
+
+		e := element{name: match, tag: tag}
+		switch tag {
+		case "":
+			// 普通 span
+		case "a":
+			href := attr(attrs, "href")
+			if has("a") || inCode() || !IsSafeURL(href) {
+				continue
+			}
+			e.attrs = ` href="` + html.EscapeString(strings.TrimSpace(href)) + `"`
+		case "code":
+			// 只有 pre 内的 code 可以带语言
+			if has("code") || (inCode() && (len(stack) == 0 || stack[len(stack)-1].tag != "pre")) {
+				continue
+			}
+			if class := attr(attrs, "class"); has("pre") && langRegex.MatchString(class) {
+				e.attrs = ` class="` + class + `"`
+			}
+		case "pre":
+			if inCode() {
+				continue
+			}
+		case "blockquote":
+			if has("blockquote") || inCode() {
+				continue
+			}
+			if hasAttr(attrs, "expandable") {
+				e.attrs = " expandable"
+			}
+		default:
+			if inCode() {
+				continue
+			}
+		}
+		if m[4] == "/" {
+			continue
+		}
+		out.WriteString(e.open())
+		stack = append(stack, e)
+	}
+
+	for j := len(stack) - 1; j >= 0; j-- {
+		out.WriteString(stack[j].close())
+	}
+	return out.String()
+}
+
+// attr get the value of an attribute
+func attr(attrs, name string) string {
+	for _, m := range attrRegex.FindAllStringSubmatch(attrs, -1) {
+		if strings.EqualFold(m[1], name) {
+			return html.UnescapeString(m[2] + m[3] + m[4])
+		}
+	}
+	return ""
+}
+
+// hasAttr check whether the attribute is present
+func hasAttr(attrs, name string) bool {
+	for _, m := range attrRegex.FindAllStringSubmatch(attrs, -1) {
+		if strings.EqualFold(m[1], name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasClass check whether the class attribute contains the class
+func hasClass(attrs, class string) bool {
+	for _, c := range strings.Fields(attr(attrs, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package tgtext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	cases := map[string]string{
+		`<strong>a</strong> <em>b</em> <del>c</del> <ins>d</ins>`:                        `<b>a</b> <i>b</i> <s>c</s> <u>d</u>`,
+		`<b>bold <i>both</b> italic</i>`:                                                 `<b>bold <i>both</i></b><i> italic</i>`,
+		`<b>open <i>never closed`:                                                        `<b>open <i>never closed</i></b>`,
+		`stray</b> a < b & c &amp; d`:                                                    `stray a &lt; b &amp; c &amp; d`,
+		`<script>alert(1)</script><p>x</p><div>y<br/>z</div>`:                            "x\ny\nz\n",
+		`<a href="javascript:alert(1)">js</a> <a href='https://t.me/x' target=_b>ok</a>`: `js <a href="https://t.me/x">ok</a>`,
+		`<pre><code class="language-go">x <b>y</b></code></pre>`:                         `<pre><code class="language-go">x y</code></pre>`,
+		`<span class="tg-spoiler">s</span><span style="x">t</span>`:                      `<tg-spoiler>s</tg-spoiler>t`,
+		`<blockquote expandable>q<blockquote>n</blockquote></blockquote>`:                `<blockquote expandable>qn</blockquote>`,
+	}
+	for in, want := range cases {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		md, html, v2 string
+	}{
+		{
+			"# Title\n\n**bold** *it* `a<b>` ~~del~~ ||spoil||.",
+			"<b>Title</b>\n\n<b>bold</b> <i>it</i> <code>a&lt;b&gt;</code> <s>del</s> <tg-spoiler>spoil</tg-spoiler>.",
+			"*Title*\n\n*bold* _it_ `a<b>` ~del~ ||spoil||\\.",
+		},
+		{
+			"[a *b*](https://example.com/x_(y)) [js](javascript:x) <https://t.me/c>",
+			`<a href="https://example.com/x_(y)">a <i>b</i></a> js <a href="https://t.me/c">https://t.me/c</a>`,
+			"[a _b_](https://example.com/x_(y\\)) js [https://t\\.me/c](https://t.me/c)",
+		},
+		{
+			"- one\n- two\n  - nested\n\n3. c",
+			"• one\n• two\n  • nested\n\n3. c",
+			"• one\n• two\n  • nested\n\n3\\. c",
+		},
+		{
+			"> quote **b**\n\n```go\nfmt.Println(\"`\\\\\")\n```",
+			"<blockquote>quote <b>b</b></blockquote>\n\n<pre><code class=\"language-go\">fmt.Println(\"`\\\\\")</code></pre>",
+			">quote *b*\n\n```go\nfmt.Println(\"\\`\\\\\\\\\")\n```",
+		},
+		{
+			"snake_case_name 5 * 3 \\*lit\\* ***x***",
+			"snake_case_name 5 * 3 *lit* <i><b>x</b></i>",
+			"snake\\_case\\_name 5 \\* 3 \\*lit\\* _*x*_",
+		},
+	}
+	for _, tc := range cases {
+		if got := MarkdownToHTML(tc.md); got != tc.html {
+			t.Errorf("MarkdownToHTML(%q) = %q, want %q", tc.md, got, tc.html)
+		}
+		if got := MarkdownToMarkdownV2(tc.md); got != tc.v2 {
+			t.Errorf("MarkdownToMarkdownV2(%q) = %q, want %q", tc.md, got, tc.v2)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	family := "👨‍👩‍👧‍👦"
+	if n := UTF16Len(family); n != 11 {
+		t.Errorf("UTF16Len(%q) = %d, want 11", family, n)
+	}
+
+	cases := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{
+			"text",
+			SplitText("Hello world. This is a test.\n\nSecond paragraph here "+family+family+" end", 20),
+			[]string{"Hello world.", "This is a test.\n\n", "Second paragraph ", "here " + family, family + " end"},
+		},
+		{
+			"html",
+			SplitHTML("<b>bold text <i>and italic &lt;x&gt; more words</i> tail</b> after", 15),
+			[]string{"<b>bold text <i>and </i></b>", "<b><i>italic &lt;x&gt; </i></b>", "<b><i>more words</i> </b>", "<b>tail</b> after"},
+		},
+		{
+			"markdownV2",
+			SplitMarkdownV2("*bold _italic text_* [a link](https://x.com/a\\)b) end\\.", 12),
+			[]string{"*bold _italic _*", "*_text_* [a link](https://x.com/a\\)b) ", "end\\."},
+		},
+	}
+	for _, tc := range cases {
+		if strings.Join(tc.got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s: split = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+
+	// 不拆分 ZWJ 序列
+	for _, chunk := range SplitText(strings.Repeat(family, 5), 12) {
+		if chunk != family {
+			t.Errorf("SplitText() chunk = %q, want %q", chunk, family)
+		}
+	}
+}
@@ -0,0 +1,557 @@
+package tgtext
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type kind int
+
+const (
+	// 块级节点
+	kindDocument kind = iota
+	kindParagraph
+	kindHeading
+	kindCodeBlock
+	kindQuote
+	kindList
+	kindItem
+	kindRule
+	// 行内节点
+	kindText
+	kindBreak
+	kindCode
+	kindBold
+	kindItalic
+	kindStrike
+	kindSpoiler
+	kindLink
+)
+
+// node of the markdown syntax tree
+type node struct {
+	kind     kind
+	text     string // 文本、代码内容
+	lang     string // 代码块语言
+	url      string // 链接地址
+	ordered  bool   // 有序列表
+	start    int    // 有序列表起始序号
+	children []*node
+
+	// 行内分隔符，解析完成后为 0
+	delim    byte
+	count    int
+	canOpen  bool
+	canClose bool
+}
+
+var (
+	fenceRegex   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})\\s*([^`\\s]*)")
+	headingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRegex    = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextRegex  = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	quoteRegex   = regexp.MustCompile(`^ {0,3}> ?`)
+	itemRegex    = regexp.MustCompile(`^( {0,3})([-+*]|(\d{1,9})[.)])( {1,4}|\t|$)`)
+)
+
+// parseMarkdown parse CommonMark text into a syntax tree
+//
+// Only the constructs Telegram can display are recognized, raw HTML is kept
+// as text, reference links and tables are left as paragraphs.
+func parseMarkdown(text string) *node {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return &node{kind: kindDocument, children: parseBlocks(strings.Split(text, "\n"))}
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// indent count the leading columns, tabs count as 4
+func indent(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// dedent remove up to n columns of leading whitespace
+func dedent(line string, n int) string {
+	col := 0
+	for i, c := range line {
+		if col >= n {
+			return line[i:]
+		}
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+			if col > n {
+				return strings.Repeat(" ", col-n) + line[i+1:]
+			}
+		default:
+			return line[i:]
+		}
+	}
+	return ""
+}
+
+// interrupts check whether the line starts a block that ends a paragraph
+func interrupts(line string) bool {
+	if fenceRegex.MatchString(line) || headingRegex.MatchString(line) || ruleRegex.MatchString(line) || quoteRegex.MatchString(line) {
+		return true
+	}
+	if m := itemRegex.FindStringSubmatch(line); m != nil && !isBlank(line[len(m[0]):]) {
+		// 有序列表只有从 1 开始才能打断段落
+		return m[3] == "" || m[3] == "1"
+	}
+	return false
+}
+
+func parseBlocks(lines []string) []*node {
+	blocks := []*node{}
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+
+		case indent(line) >= 4:
+			// 缩进代码块
+			code := []string{}
+			for ; i < len(lines) && (indent(lines[i]) >= 4 || isBlank(lines[i])); i++ {
+				code = append(code, dedent(lines[i], 4))
+			}
+			for len(code) > 0 && isBlank(code[len(code)-1]) {
+				code = code[:len(code)-1]
+			}
+			blocks = append(blocks, &node{kind: kindCodeBlock, text: strings.Join(code, "\n")})
+
+		case fenceRegex.MatchString(line):
+			m := fenceRegex.FindStringSubmatch(line)
+			fence, pad := m[1], indent(line)
+			code := []string{}
+			for i++; i < len(lines); i++ {
+				closing := strings.TrimSpace(lines[i])
+				if indent(lines[i]) < 4 && strings.HasPrefix(closing, fence) && strings.Trim(closing, fence[:1]) == "" {
+					i++
+					break
+				}
+				code = append(code, dedent(lines[i], pad))
+			}
+			blocks = append(blocks, &node{kind: kindCodeBlock, lang: m[2], text: strings.Join(code, "\n")})
+
+		case headingRegex.MatchString(line):
+			m := headingRegex.FindStringSubmatch(line)
+			blocks = append(blocks, &node{kind: kindHeading, children: parseInline(m[2])})
+			i++
+
+		case ruleRegex.MatchString(line):
+			blocks = append(blocks, &node{kind: kindRule})
+			i++
+
+		case quoteRegex.MatchString(line):
+			inner := []string{}
+			for ; i < len(lines); i++ {
+				if quoteRegex.MatchString(lines[i]) {
+					inner = append(inner, quoteRegex.ReplaceAllString(lines[i], ""))
+				} else if !isBlank(lines[i]) && len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !interrupts(lines[i]) {
+					// 懒惰续行
+					inner = append(inner, lines[i])
+				} else {
+					break
+				}
+			}
+			blocks = append(blocks, &node{kind: kindQuote, children: parseBlocks(inner)})
+
+		case itemRegex.MatchString(line):
+			var list *node
+			list, i = parseList(lines, i)
+			blocks = append(blocks, list)
+
+		default:
+			para := []string{strings.TrimSpace(line)}
+			for i++; i < len(lines) && !isBlank(lines[i]); i++ {
+				if m := setextRegex.FindStringSubmatch(lines[i]); m != nil {
+					blocks = append(blocks, &node{kind: kindHeading, children: parseInline(strings.Join(para, "\n"))})
+					para = nil
+					i++
+					break
+				}
+				if interrupts(lines[i]) {
+					break
+				}
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			if para != nil {
+				blocks = append(blocks, &node{kind: kindParagraph, children: parseInline(strings.Join(para, "\n"))})
+			}
+		}
+	}
+	return blocks
+}
+
+// parseList parse consecutive items of the same list type
+func parseList(lines []string, i int) (*node, int) {
+	first := itemRegex.FindStringSubmatch(lines[i])
+	list := &node{kind: kindList, ordered: first[3] != "", start: 1}
+	if list.ordered {
+		list.start, _ = strconv.Atoi(first[3])
+	}
+	marker := first[2][len(first[2])-1:]
+
+	for i < len(lines) {
+		m := itemRegex.FindStringSubmatch(lines[i])
+		if m == nil || m[2][len(m[2])-1:] != marker {
+			break
+		}
+		// 内容缩进，标记后超过 4 个空格时视为 1 个
+		width := len(m[1]) + len(m[2]) + 1
+		if spaces := len(m[4]); spaces >= 1 && spaces <= 4 && m[4] != "\t" {
+			width = len(m[1]) + len(m[2]) + spaces
+		}
+		inner := []string{lines[i][len(m[0]):]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if isBlank(line) {
+				// 空行后需要缩进才属于当前项
+				if i+1 < len(lines) && !isBlank(lines[i+1]) && indent(lines[i+1]) >= width {
+					inner = append(inner, "")
+					continue
+				}
+				if i+1 < len(lines) && itemRegex.MatchString(lines[i+1]) && indent(lines[i+1]) < width {
+					continue
+				}
+				break
+			}
+			if indent(line) >= width {
+				inner = append(inner, dedent(line, width))
+				continue
+			}
+			if itemRegex.MatchString(line) || interrupts(line) || isBlank(inner[len(inner)-1]) {
+				break
+			}
+			// 懒惰续行
+			inner = append(inner, line)
+		}
+		list.children = append(list.children, &node{kind: kindItem, children: parseBlocks(inner)})
+	}
+	return list, i
+}
+
+func isPunct(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// parseInline parse the inline content of a block
+func parseInline(text string) []*node {
+	nodes := []*node{}
+	brackets := []int{} // 未闭合的 [ 在 nodes 中的位置
+	buf := strings.Builder{}
+
+	flush := func() {
+		if buf.Len() > 0 {
+			nodes = append(nodes, &node{kind: kindText, text: buf.String()})
+			buf.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			flush()
+			nodes = append(nodes, &node{kind: kindBreak})
+			i += 2
+
+		case c == '\\' && i+1 < len(text) && text[i+1] < utf8.RuneSelf && isPunct(rune(text[i+1])):
+			buf.WriteByte(text[i+1])
+			i += 2
+
+		case c == '\n':
+			// 聊天消息中保留换行
+			s := strings.TrimRight(buf.String(), " ")
+			buf.Reset()
+			buf.WriteString(s)
+			flush()
+			nodes = append(nodes, &node{kind: kindBreak})
+			i++
+
+		case c == '`':
+			n := 0
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			end := findBackticks(text[i+n:], n)
+			if end < 0 {
+				buf.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+			code := strings.ReplaceAll(text[i+n:i+n+end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			flush()
+			nodes = append(nodes, &node{kind: kindCode, text: code})
+			i += n + end + n
+
+		case c == '<':
+			// 自动链接
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				link := text[i+1 : i+end]
+				if !strings.ContainsAny(link, " <\n") && IsSafeURL(link) {
+					flush()
+					nodes = append(nodes, &node{kind: kindLink, url: link, children: []*node{{kind: kindText, text: link}}})
+					i += end + 1
+					continue
+				}
+			}
+			buf.WriteByte(c)
+			i++
+
+		case c == '[' || (c == '!' && i+1 < len(text) && text[i+1] == '['):
+			flush()
+			n := 1
+			if c == '!' {
+				n = 2
+			}
+			brackets = append(brackets, len(nodes))
+			nodes = append(nodes, &node{kind: kindText, text: text[i : i+n]})
+			i += n
+
+		case c == ']':
+			if len(brackets) == 0 {
+				buf.WriteByte(c)
+				i++
+				continue
+			}
+			flush()
+			open := brackets[len(brackets)-1]
+			brackets = brackets[:len(brackets)-1]
+			dest, n := parseLinkDest(text[i+1:])
+			if n == 0 {
+				nodes = append(nodes, &node{kind: kindText, text: "]"})
+				i++
+				continue
+			}
+			image := nodes[open].text == "!["
+			children := processEmphasis(append([]*node{}, nodes[open+1:]...))
+			nodes = append(nodes[:open], &node{kind: kindLink, url: dest, children: children})
+			if !image {
+				// 链接内不能再有链接，外层的 [ 作为文本
+				kept := brackets[:0]
+				for _, b := range brackets {
+					if nodes[b].text == "![" {
+						kept = append(kept, b)
+					}
+				}
+				brackets = kept
+			}
+			i += 1 + n
+
+		case c == '*' || c == '_' || c == '~' || c == '|':
+			n := 0
+			for i+n < len(text) && text[i+n] == c {
+				n++
+			}
+			before, _ := utf8.DecodeLastRuneInString(text[:i])
+			after, _ := utf8.DecodeRuneInString(text[i+n:])
+			if i == 0 {
+				before = ' '
+			}
+			if i+n == len(text) {
+				after = ' '
+			}
+			left := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+			right := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+			d := &node{kind: kindText, text: text[i : i+n], delim: c, count: n, canOpen: left, canClose: right}
+			if c == '_' {
+				d.canOpen = left && (!right || isPunct(before))
+				d.canClose = right && (!left || isPunct(after))
+			}
+			if (c == '|' && n != 2) || (c == '~' && n > 2) {
+				d.delim = 0
+			}
+			flush()
+			nodes = append(nodes, d)
+			i += n
+
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return processEmphasis(nodes)
+}
+
+// findBackticks find a run of exactly n backticks
+func findBackticks(s string, n int) int {
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		if j-i == n {
+			return i
+		}
+		i = j
+	}
+	return -1
+}
+
+// parseLinkDest parse "(url)" or "(url "title")" after the closing bracket
+func parseLinkDest(s string) (string, int) {
+	if !strings.HasPrefix(s, "(") {
+		return "", 0
+	}
+	i := 1
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	var dest strings.Builder
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", 0
+		}
+		dest.WriteString(s[i+1 : i+1+end])
+		i += end + 2
+	} else {
+		depth := 0
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) && isPunct(rune(s[i+1])) {
+				dest.WriteByte(s[i+1])
+				i++
+				continue
+			}
+			if c == ' ' || c == '\n' || c < 0x20 {
+				break
+			}
+			if c == '(' {
+				depth++
+			}
+			if c == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+			dest.WriteByte(c)
+		}
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	// 忽略标题
+	if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+		end := strings.IndexByte(s[i+1:], s[i])
+		if end < 0 {
+			return "", 0
+		}
+		i += end + 2
+		for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+			i++
+		}
+	}
+	if i >= len(s) || s[i] != ')' {
+		return "", 0
+	}
+	return dest.String(), i + 1
+}
+
+// processEmphasis match delimiter runs into emphasis nodes
+func processEmphasis(nodes []*node) []*node {
+	for i := 0; i < len(nodes); i++ {
+		closer := nodes[i]
+		if closer.delim == 0 || !closer.canClose {
+			continue
+		}
+		o := -1
+		for j := i - 1; j >= 0; j-- {
+			opener := nodes[j]
+			if opener.delim != closer.delim || !opener.canOpen {
+				continue
+			}
+			// CommonMark 的 3 的倍数规则
+			if (closer.delim == '*' || closer.delim == '_') && (opener.canClose || closer.canOpen) &&
+				(opener.count+closer.count)%3 == 0 && (opener.count%3 != 0 || closer.count%3 != 0) {
+				continue
+			}
+			if (closer.delim == '~' || closer.delim == '|') && opener.count != closer.count {
+				continue
+			}
+			o = j
+			break
+		}
+		if o < 0 {
+			if !closer.canOpen {
+				closer.delim = 0
+			}
+			continue
+		}
+
+		opener := nodes[o]
+		use := 1
+		k := kindItalic
+		switch closer.delim {
+		case '~':
+			use, k = closer.count, kindStrike
+		case '|':
+			use, k = 2, kindSpoiler
+		default:
+			if opener.count >= 2 && closer.count >= 2 {
+				use, k = 2, kindBold
+			}
+		}
+
+		// 中间未匹配的分隔符转为文本
+		inner := nodes[o+1 : i]
+		for _, n := range inner {
+			n.delim = 0
+		}
+		wrapped := &node{kind: k, children: append([]*node{}, inner...)}
+
+		opener.count -= use
+		opener.text = opener.text[:opener.count]
+		closer.count -= use
+		closer.text = closer.text[:closer.count]
+
+		rebuilt := append([]*node{}, nodes[:o]...)
+		if opener.count > 0 {
+			rebuilt = append(rebuilt, opener)
+		}
+		rebuilt = append(rebuilt, wrapped)
+		next := len(rebuilt)
+		if closer.count > 0 {
+			rebuilt = append(rebuilt, closer)
+		}
+		rebuilt = append(rebuilt, nodes[i+1:]...)
+		nodes = rebuilt
+		// 剩余的 closer 继续匹配
+		i = next - 1
+	}
+	for _, n := range nodes {
+		n.delim = 0
+	}
+	return nodes
+}
@@ -0,0 +1,174 @@
+package tgtext
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MarkdownToHTML convert CommonMark text to Telegram HTML
+//
+// Headings become bold, lists are rendered with "•" or numbers, soft line
+// breaks are kept since chat messages are not reflowed, links with unsafe
+// schemes are rendered as plain text. GFM "~~strike~~" and "||spoiler||"
+// are supported as well.
+func MarkdownToHTML(md string) string {
+	r := &renderer{}
+	return r.blocks(parseMarkdown(md).children, false)
+}
+
+// MarkdownToMarkdownV2 convert CommonMark text to Telegram MarkdownV2
+func MarkdownToMarkdownV2(md string) string {
+	r := &renderer{v2: true}
+	return r.blocks(parseMarkdown(md).children, false)
+}
+
+// renderer render the syntax tree in HTML or MarkdownV2 mode
+type renderer struct {
+	v2 bool
+	// open 当前已打开的实体，避免重复嵌套
+	open map[kind]bool
+}
+
+func (r *renderer) blocks(blocks []*node, inQuote bool) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, r.block(b, inQuote))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func (r *renderer) block(b *node, inQuote bool) string {
+	switch b.kind {
+	case kindParagraph:
+		return r.inlines(b.children)
+
+	case kindHeading:
+		return r.wrap(kindBold, b.children)
+
+	case kindCodeBlock:
+		if r.v2 {
+			return "```" + b.lang + "\n" + escapeMarkdownV2Code(b.text) + "\n```"
+		}
+		if b.lang != "" && langRegex.MatchString("language-"+b.lang) {
+			return `<pre><code class="language-` + b.lang + `">` + EscapeHTML(b.text) + "</code></pre>"
+		}
+		return "<pre>" + EscapeHTML(b.text) + "</pre>"
+
+	case kindQuote:
+		inner := r.blocks(b.children, true)
+		// Telegram 不支持嵌套引用
+		if inQuote {
+			return inner
+		}
+		if r.v2 {
+			lines := strings.Split(inner, "\n")
+			for i, line := range lines {
+				lines[i] = ">" + line
+			}
+			return strings.Join(lines, "\n")
+		}
+		return "<blockquote>" + inner + "</blockquote>"
+
+	case kindList:
+		items := make([]string, 0, len(b.children))
+		for i, item := range b.children {
+			marker := "• "
+			if b.ordered {
+				marker = strconv.Itoa(b.start+i) + ". "
+			}
+			parts := make([]string, 0, len(item.children))
+			for _, child := range item.children {
+				parts = append(parts, r.block(child, inQuote))
+			}
+			content := strings.ReplaceAll(strings.Join(parts, "\n"), "\n", "\n"+strings.Repeat(" ", utf8.RuneCountInString(marker)))
+			if r.v2 {
+				marker = EscapeMarkdownV2(marker)
+			}
+			items = append(items, marker+content)
+		}
+		return strings.Join(items, "\n")
+
+	case kindRule:
+		return "———"
+	}
+	return ""
+}
+
+func (r *renderer) inlines(nodes []*node) string {
+	var out strings.Builder
+	for _, n := range nodes {
+		out.WriteString(r.inline(n))
+	}
+	return out.String()
+}
+
+func (r *renderer) inline(n *node) string {
+	switch n.kind {
+	case kindText:
+		if r.v2 {
+			return EscapeMarkdownV2(n.text)
+		}
+		return EscapeHTML(n.text)
+
+	case kindBreak:
+		return "\n"
+
+	case kindCode:
+		if r.v2 {
+			return "`" + escapeMarkdownV2Code(n.text) + "`"
+		}
+		return "<code>" + EscapeHTML(n.text) + "</code>"
+
+	case kindLink:
+		if r.open[kindLink] || !IsSafeURL(n.url) {
+			return r.inlines(n.children)
+		}
+		r.enter(kindLink)
+		text := r.inlines(n.children)
+		r.leave(kindLink)
+		if r.v2 {
+			return "[" + text + "](" + escapeMarkdownV2URL(n.url) + ")"
+		}
+		return `<a href="` + html.EscapeString(n.url) + `">` + text + "</a>"
+	}
+	return r.wrap(n.kind, n.children)
+}
+
+// markers of the formatting entities, [HTML open, HTML close, MarkdownV2]
+var markers = map[kind][3]string{
+	kindBold:    {"<b>", "</b>", "*"},
+	kindItalic:  {"<i>", "</i>", "_"},
+	kindStrike:  {"<s>", "</s>", "~"},
+	kindSpoiler: {"<tg-spoiler>", "</tg-spoiler>", "||"},
+}
+
+// wrap render children inside a formatting entity
+func (r *renderer) wrap(k kind, children []*node) string {
+	if r.open[k] {
+		return r.inlines(children)
+	}
+	r.enter(k)
+	text := r.inlines(children)
+	r.leave(k)
+	if text == "" {
+		return ""
+	}
+	m := markers[k]
+	if !r.v2 {
+		return m[0] + text + m[1]
+	}
+	return m[2] + text + m[2]
+}
+
+func (r *renderer) enter(k kind) {
+	if r.open == nil {
+		r.open = map[kind]bool{}
+	}
+	r.open[k] = true
+}
+
+func (r *renderer) leave(k kind) {
+	delete(r.open, k)
+}
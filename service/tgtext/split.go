@@ -0,0 +1,441 @@
+package tgtext
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/mylukin/EchoPilot/service/emoji"
+)
+
+const (
+	// MaxMessageLength is the limit of a text message in UTF-16 code units
+	MaxMessageLength = 4096
+	// MaxCaptionLength is the limit of a media caption in UTF-16 code units
+	MaxCaptionLength = 1024
+)
+
+var entityRegex = regexp.MustCompile(`^&(?:#[0-9]+|#[xX][0-9a-fA-F]+|[a-zA-Z][a-zA-Z0-9]*);`)
+
+// UTF16Len length of the text as counted by Telegram
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+type tokenKind int
+
+const (
+	tokText  tokenKind = iota
+	tokOpen            // 实体开始
+	tokClose           // 实体结束
+	tokMark            // 不可见且无需闭合，如引用的 ">"
+)
+
+// token of formatted text, markup has no width
+type token struct {
+	kind  tokenKind
+	raw   string // 原始内容
+	text  string // 可见文本
+	width int
+	name  string // 实体名称
+	close string // 实体的结束标记
+}
+
+func textToken(raw, text string) token {
+	return token{kind: tokText, raw: raw, text: text, width: UTF16Len(text)}
+}
+
+// SplitText split plain text into chunks of at most limit UTF-16 units
+//
+// Chunks end at paragraph, line, sentence or word boundaries when possible,
+// grapheme clusters such as emoji ZWJ sequences are never split.
+func SplitText(text string, limit int) []string {
+	tokens := []token{}
+	for _, g := range graphemes(text) {
+		tokens = append(tokens, textToken(g, g))
+	}
+	return split(tokens, limit)
+}
+
+// SplitHTML split Telegram HTML, tags open at a boundary are closed at the
+// end of the chunk and reopened at the start of the next one
+//
+// The length is counted on the visible text, so "&lt;" counts as one unit.
+// The input should be well formed, see Sanitize.
+func SplitHTML(text string, limit int) []string {
+	tokens := []token{}
+	for len(text) > 0 {
+		switch text[0] {
+		case '<':
+			if m := tagRegex.FindStringSubmatch(text); m != nil {
+				name := strings.ToLower(m[2])
+				switch {
+				case m[4] == "/":
+					tokens = append(tokens, token{kind: tokMark, raw: m[0]})
+				case m[1] == "/":
+					tokens = append(tokens, token{kind: tokClose, raw: m[0], name: name})
+				default:
+					tokens = append(tokens, token{kind: tokOpen, raw: m[0], name: name, close: "</" + name + ">"})
+				}
+				text = text[len(m[0]):]
+				continue
+			}
+			tokens = append(tokens, textToken("<", "<"))
+			text = text[1:]
+		case '&':
+			if m := entityRegex.FindString(text); m != "" {
+				tokens = append(tokens, textToken(m, html.UnescapeString(m)))
+				text = text[len(m):]
+				continue
+			}
+			tokens = append(tokens, textToken("&", "&"))
+			text = text[1:]
+		default:
+			end := strings.IndexAny(text, "<&")
+			if end < 0 {
+				end = len(text)
+			}
+			for _, g := range graphemes(text[:end]) {
+				tokens = append(tokens, textToken(g, g))
+			}
+			text = text[end:]
+		}
+	}
+	return split(tokens, limit)
+}
+
+// markdownV2Entities toggle markers of MarkdownV2, longest first
+var markdownV2Entities = []string{"||", "__", "*", "_", "~"}
+
+// SplitMarkdownV2 split Telegram MarkdownV2 text, entities open at a
+// boundary are closed and reopened in the next chunk
+func SplitMarkdownV2(text string, limit int) []string {
+	tokens := []token{}
+	open := map[string]bool{}
+	links := map[int]string{} // "]" 的位置 => "](url)"
+	inPre, inCode := false, false
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		lineStart := i == 0 || text[i-1] == '\n'
+
+		// 转义字符
+		if rest[0] == '\\' && len(rest) > 1 {
+			_, size := utf8.DecodeRuneInString(rest[1:])
+			tokens = append(tokens, textToken(rest[:1+size], rest[1:1+size]))
+			i += 1 + size
+			continue
+		}
+
+		switch {
+		case inPre && strings.HasPrefix(rest, "```"):
+			tokens = append(tokens, token{kind: tokClose, raw: "```", name: "pre"})
+			inPre = false
+			i += 3
+			continue
+		case inCode && rest[0] == '`':
+			tokens = append(tokens, token{kind: tokClose, raw: "`", name: "code"})
+			inCode = false
+			i++
+			continue
+		case inPre || inCode:
+			// 代码中只有转义和结束标记
+		case strings.HasPrefix(rest, "```"):
+			raw := "```"
+			if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+				raw = rest[:nl+1]
+			}
+			tokens = append(tokens, token{kind: tokOpen, raw: raw, name: "pre", close: "```"})
+			inPre = true
+			i += len(raw)
+			continue
+		case rest[0] == '`':
+			tokens = append(tokens, token{kind: tokOpen, raw: "`", name: "code", close: "`"})
+			inCode = true
+			i++
+			continue
+		case lineStart && (rest[0] == '>' || strings.HasPrefix(rest, "**>")):
+			n := 1
+			if rest[0] == '*' {
+				n = 3
+			}
+			tokens = append(tokens, token{kind: tokMark, raw: rest[:n]})
+			i += n
+			continue
+		case rest[0] == '[':
+			if end, close := findLinkEnd(rest); end > 0 {
+				links[i+end] = close
+				tokens = append(tokens, token{kind: tokOpen, raw: "[", name: "link", close: close})
+				i++
+				continue
+			}
+		case rest[0] == ']' && links[i] != "":
+			tokens = append(tokens, token{kind: tokClose, raw: links[i], name: "link"})
+			i += len(links[i])
+			continue
+		default:
+			matched := ""
+			for _, marker := range markdownV2Entities {
+				if strings.HasPrefix(rest, marker) {
+					matched = marker
+					break
+				}
+			}
+			if matched != "" {
+				if open[matched] {
+					tokens = append(tokens, token{kind: tokClose, raw: matched, name: matched})
+				} else {
+					tokens = append(tokens, token{kind: tokOpen, raw: matched, name: matched, close: matched})
+				}
+				open[matched] = !open[matched]
+				i += len(matched)
+				continue
+			}
+		}
+
+		// 普通文本，到下一个可能的标记为止
+		end := strings.IndexAny(rest[1:], "\\`_*~|[]>\n")
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end++
+		}
+		if rest[0] == '\n' {
+			end = 1
+		}
+		for _, g := range graphemes(rest[:end]) {
+			tokens = append(tokens, textToken(g, g))
+		}
+		i += end
+	}
+	return split(tokens, limit)
+}
+
+// findLinkEnd find the "](url)" closing a link started at s[0]
+func findLinkEnd(s string) (int, string) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return 0, ""
+		case ']':
+			if !strings.HasPrefix(s[i:], "](") {
+				return 0, ""
+			}
+			for j := i + 2; j < len(s); j++ {
+				if s[j] == '\\' {
+					j++
+					continue
+				}
+				if s[j] == ')' {
+					return i, s[i : j+1]
+				}
+			}
+			return 0, ""
+		}
+	}
+	return 0, ""
+}
+
+// split group tokens into chunks of at most limit visible units
+func split(tokens []token, limit int) []string {
+	if limit <= 0 {
+		limit = MaxMessageLength
+	}
+	chunks := []string{}
+	stack := []token{}
+
+	inCode := func() bool {
+		for _, t := range stack {
+			if t.name == "pre" || t.name == "code" {
+				return true
+			}
+		}
+		return false
+	}
+
+	for s := 0; s < len(tokens); {
+		// 块之间的空白由 Telegram 去除，直接跳过
+		for s < len(tokens) && tokens[s].kind == tokText && strings.TrimSpace(tokens[s].text) == "" && !inCode() {
+			s++
+		}
+		if s == len(tokens) {
+			break
+		}
+
+		// 在限制内能放下的最远位置
+		e, width := s, 0
+		for e < len(tokens) && width+tokens[e].width <= limit {
+			width += tokens[e].width
+			e++
+		}
+		cut := e
+		if e < len(tokens) {
+			cut = breakAt(tokens, s, e, limit)
+			// 紧随的结束标记留在当前块
+			for cut < len(tokens) && tokens[cut].kind == tokClose {
+				cut++
+			}
+		}
+
+		var chunk strings.Builder
+		for _, t := range stack {
+			chunk.WriteString(t.raw)
+		}
+		visible := false
+		for _, t := range tokens[s:cut] {
+			chunk.WriteString(t.raw)
+			switch t.kind {
+			case tokText:
+				visible = visible || strings.TrimSpace(t.text) != ""
+			case tokOpen:
+				stack = append(stack, t)
+			case tokClose:
+				for j := len(stack) - 1; j >= 0; j-- {
+					if stack[j].name == t.name {
+						stack = append(stack[:j], stack[j+1:]...)
+						break
+					}
+				}
+			}
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			chunk.WriteString(stack[j].close)
+		}
+		if visible {
+			chunks = append(chunks, chunk.String())
+		}
+		s = cut
+	}
+	return chunks
+}
+
+// breakAt choose where to end the chunk in tokens[s:e]
+//
+// The latest paragraph break wins, then line, sentence and word breaks, as
+// long as the chunk stays at least half full.
+func breakAt(tokens []token, s, e, limit int) int {
+	best, bestLevel := e, 0
+	width := 0
+	widths := make([]int, e-s+1)
+	for k := s; k < e; k++ {
+		width += tokens[k].width
+		widths[k-s+1] = width
+	}
+	for k := e - 1; k > s; k-- {
+		if tokens[k].kind != tokText || widths[k-s+1] < limit/2 {
+			continue
+		}
+		if level := breakLevel(tokens, k); level > bestLevel {
+			best, bestLevel = k+1, level
+			if level == 4 {
+				break
+			}
+		}
+	}
+	if e == s {
+		// 单个字素超过限制
+		return s + 1
+	}
+	return best
+}
+
+// breakLevel how good it is to break after tokens[k]
+func breakLevel(tokens []token, k int) int {
+	text := strings.TrimPrefix(tokens[k].text, "\r")
+	prev, next := "", ""
+	for j := k - 1; j >= 0; j-- {
+		if tokens[j].kind == tokText {
+			prev = strings.TrimPrefix(tokens[j].text, "\r")
+			break
+		}
+	}
+	for j := k + 1; j < len(tokens); j++ {
+		if tokens[j].kind == tokText {
+			next = tokens[j].text
+			break
+		}
+	}
+	switch {
+	case text == "\n" && prev == "\n":
+		return 4
+	case text == "\n":
+		return 3
+	case strings.ContainsAny(text, "。！？；…") || (strings.ContainsAny(text, ".!?;") && (next == "" || strings.TrimSpace(next) == "")):
+		return 2
+	case strings.TrimSpace(text) == "":
+		return 1
+	}
+	return 0
+}
+
+var (
+	emojiOnce     sync.Once
+	emojiSeqs     map[string]bool
+	emojiMaxRunes int
+)
+
+// loadEmojiSeqs index the multi-rune sequences of service/emoji
+func loadEmojiSeqs() {
+	emojiSeqs = map[string]bool{}
+	for _, e := range emoji.Emojis {
+		n := utf8.RuneCountInString(e.Value)
+		if n < 2 {
+			continue
+		}
+		emojiSeqs[e.Value] = true
+		if n > emojiMaxRunes {
+			emojiMaxRunes = n
+		}
+	}
+}
+
+// isExtender runes that attach to the previous one
+func isExtender(r rune) bool {
+	return r == 0x200D || r == 0xFE0E || r == 0xFE0F || r == 0x20E3 ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F) ||
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc)
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// graphemes split text into clusters that must stay together
+func graphemes(s string) []string {
+	emojiOnce.Do(loadEmojiSeqs)
+	runes := []rune(s)
+	out := make([]string, 0, len(runes))
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if runes[i] >= 0xA9 {
+			for l := min(emojiMaxRunes, len(runes)-i); l > 1; l-- {
+				if emojiSeqs[string(runes[i:i+l])] {
+					j = i + l
+					break
+				}
+			}
+		}
+		if j == i+1 && isRegionalIndicator(runes[i]) && j < len(runes) && isRegionalIndicator(runes[j]) {
+			j++
+		}
+		if runes[i] == '\r' && j < len(runes) && runes[j] == '\n' {
+			j++
+		}
+		for j < len(runes) && (isExtender(runes[j]) || runes[j-1] == 0x200D) {
+			j++
+		}
+		out = append(out, string(runes[i:j]))
+		i = j
+	}
+	return out
+}